@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A snippet as returned by the API. Only the fields we show are decoded.
+type snippet struct {
+	ID         int        `json:"id"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Language   string     `json:"language"`
+	Tags       []string   `json:"tags"`
+	Visibility string     `json:"visibility"`
+	Slug       string     `json:"slug"`
+	Author     string     `json:"author"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires"`
+}
+
+// Body of a create request, see snippetRequest in cmd/web/api.go
+type newSnippet struct {
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	Language    string   `json:"language,omitempty"`
+	Markdown    bool     `json:"markdown,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Visibility  string   `json:"visibility,omitempty"`
+	MaxViews    int      `json:"max_views,omitempty"`
+	Expires     int      `json:"expires,omitempty"`
+	ExpiresUnit string   `json:"expires_unit,omitempty"`
+}
+
+// Talks to the JSON API of a snippetbox server
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newClient(cfg *config) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &client{
+		server: cfg.Server,
+		token:  cfg.Token,
+		http:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// An error response from the API
+type apiError struct {
+	Status      int
+	Message     string            // from {"error": ...}
+	FieldErrors map[string]string // from {"errors": {...}}, sent when validation fails
+}
+
+func (e *apiError) Error() string {
+	if len(e.FieldErrors) == 0 {
+		return fmt.Sprintf("server said %d: %s", e.Status, e.Message)
+	}
+
+	// Sorted so the output is the same every time
+	fields := make([]string, 0, len(e.FieldErrors))
+	for field := range e.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + ": " + e.FieldErrors[field]
+	}
+	return "invalid snippet: " + strings.Join(msgs, "; ")
+}
+
+// Sends a request to the API endpoint at path (relative to /api/v1) with body
+// as the JSON request body (if not nil) and decodes the response into dest.
+func (c *client) do(method, path string, body any, dest any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.server+"/api/v1"+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		e := &apiError{Status: res.StatusCode}
+		var payload struct {
+			Error  string            `json:"error"`
+			Errors map[string]string `json:"errors"`
+		}
+		if json.NewDecoder(res.Body).Decode(&payload) == nil {
+			e.Message, e.FieldErrors = payload.Error, payload.Errors
+		}
+		if e.Message == "" {
+			e.Message = http.StatusText(res.StatusCode)
+		}
+		return e
+	}
+
+	if dest == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(dest)
+}
+
+// Creates a snippet and returns it as stored
+func (c *client) create(s *newSnippet) (*snippet, error) {
+	var res struct {
+		Snippet *snippet `json:"snippet"`
+	}
+	err := c.do(http.MethodPost, "/snippets", s, &res)
+	return res.Snippet, err
+}
+
+// Fetches the snippet with the given id
+func (c *client) get(id int) (*snippet, error) {
+	var res struct {
+		Snippet *snippet `json:"snippet"`
+	}
+	err := c.do(http.MethodGet, fmt.Sprintf("/snippets/%d", id), nil, &res)
+	return res.Snippet, err
+}
+
+// Lists the latest public snippets, only those tagged with tag if it isn't empty
+func (c *client) latest(tag string) ([]*snippet, error) {
+	path := "/snippets"
+	if tag != "" {
+		path += "?tag=" + url.QueryEscape(tag)
+	}
+
+	var res struct {
+		Snippets []*snippet `json:"snippets"`
+	}
+	err := c.do(http.MethodGet, path, nil, &res)
+	return res.Snippets, err
+}
+
+// Returns the given page of search results for query
+func (c *client) search(query string, page int) ([]*snippet, error) {
+	qs := url.Values{}
+	qs.Set("q", query)
+	qs.Set("page", fmt.Sprint(page))
+
+	var res struct {
+		Snippets []*snippet `json:"snippets"`
+	}
+	err := c.do(http.MethodGet, "/search?"+qs.Encode(), nil, &res)
+	return res.Snippets, err
+}
+
+// Returns the URL of the page showing s in a browser
+func (c *client) viewURL(s *snippet) string {
+	if s.Visibility == "unlisted" && s.Slug != "" {
+		return c.server + "/s/" + s.Slug
+	}
+	return fmt.Sprintf("%s/snippet/view/%d", c.server, s.ID)
+}
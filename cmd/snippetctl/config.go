@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Settings read from the config file, e.g.
+//
+//	{
+//		"server": "https://snippets.example.com",
+//		"token": "<personal API token from /account/tokens>"
+//	}
+type config struct {
+	Server string `json:"server"` // base URL of the web server
+	Token  string `json:"token"`  // sent as a bearer token, may be empty for read-only use
+	// Skips TLS certificate verification, for servers using the self-signed
+	// development certificate
+	Insecure bool `json:"insecure"`
+}
+
+// Returns the default location of the config file:
+// $XDG_CONFIG_HOME/snippetctl/config.json (~/.config on Linux) or the
+// platform's equivalent.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "snippetctl.json"
+	}
+	return filepath.Join(dir, "snippetctl", "config.json")
+}
+
+// Reads the config file at path
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no config file at %s (see snippetctl -h)", path)
+		}
+		return nil, err
+	}
+
+	cfg := &config{}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("%s doesn't set \"server\"", path)
+	}
+	cfg.Server = strings.TrimRight(cfg.Server, "/")
+
+	return cfg, nil
+}
@@ -0,0 +1,306 @@
+// Command snippetctl talks to a snippetbox server from the terminal:
+//
+//	make test 2>&1 | snippetctl create -title "Failing tests"
+//	snippetctl create -tags k8s,ops deploy.yaml
+//	snippetctl get 42 | kubectl apply -f -
+//	snippetctl list -tag k8s
+//	snippetctl search connection pool
+//
+// The server and an API token are read from a JSON config file, see config.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+const usage = `Usage: snippetctl [-config file] <command> [arguments]
+
+Commands:
+  create [flags] [file]   create a snippet from file, or stdin if there is none (or it is -)
+  get [-json] <id>        print the content of a snippet
+  list [-tag tag]         list the latest public snippets
+  search <query>...       search the public snippets
+
+Run snippetctl <command> -h for the flags of a command.
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "snippetctl:", err)
+		os.Exit(1)
+	}
+}
+
+// Runs the command line args (without the program name). Split from main so
+// it can be tested.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("snippetctl", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath(), "Path of the config file")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	c := newClient(cfg)
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "create":
+		return createCmd(c, args, stdin, stdout)
+	case "get":
+		return getCmd(c, args, stdout)
+	case "list":
+		return listCmd(c, args, stdout)
+	case "search":
+		return searchCmd(c, args, stdout)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// Creates a snippet and prints its URL
+func createCmd(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	title := fs.String("title", "", "Title (defaults to the file name, or the first line when reading stdin)")
+	lang := fs.String("lang", "", "Language used for highlighting (guessed from the file extension by default)")
+	markdown := fs.Bool("markdown", false, "Render the snippet as Markdown")
+	tags := fs.String("tags", "", "Comma separated tags")
+	visibility := fs.String("visibility", "", "public, unlisted or private (the server defaults to public)")
+	maxViews := fs.Int("max-views", 0, "Delete the snippet after this many views, 1 to burn after reading")
+	expires := fs.String("expires", "", `Lifetime such as "12h", "7d", "3m" or "never" (the server defaults to a year)`)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("create takes at most one file")
+	}
+
+	s := &newSnippet{
+		Title:      *title,
+		Language:   *lang,
+		Markdown:   *markdown,
+		Visibility: *visibility,
+		MaxViews:   *maxViews,
+	}
+
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			s.Tags = append(s.Tags, tag)
+		}
+	}
+
+	if *expires != "" {
+		s.Expires, s.ExpiresUnit, err = parseExpires(*expires)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Read the content and fill in defaults based on where it came from
+	file := fs.Arg(0)
+	var content []byte
+	if file == "" || file == "-" {
+		content, err = io.ReadAll(stdin)
+		if s.Title == "" {
+			s.Title = firstLine(string(content))
+		}
+	} else {
+		content, err = os.ReadFile(file)
+		if s.Title == "" {
+			s.Title = filepath.Base(file)
+		}
+		if s.Language == "" {
+			s.Language = languageForFile(file)
+		}
+		if filepath.Ext(file) == ".md" {
+			s.Markdown = true
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.Content = string(content)
+
+	created, err := c.create(s)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, c.viewURL(created))
+	return nil
+}
+
+// Prints the content of a snippet, or all of it as JSON with -json
+func getCmd(c *client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the whole snippet as JSON")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("get takes exactly one snippet id")
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || id < 1 {
+		return fmt.Errorf("invalid snippet id %q", fs.Arg(0))
+	}
+
+	s, err := c.get(id)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(stdout, s)
+	}
+
+	_, err = io.WriteString(stdout, s.Content)
+	return err
+}
+
+// Lists the latest public snippets
+func listCmd(c *client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	tag := fs.String("tag", "", "Only list snippets with this tag")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	snippets, err := c.latest(*tag)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(stdout, snippets)
+}
+
+// Searches the public snippets, the words of the query may be given as
+// separate arguments
+func searchCmd(c *client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	page := fs.Int("page", 1, "Page of results to show")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return errors.New("search needs a query")
+	}
+
+	snippets, err := c.search(query, *page)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(stdout, snippets)
+}
+
+// Prints one line per snippet
+func writeTable(w io.Writer, snippets []*snippet) error {
+	if len(snippets) == 0 {
+		_, err := fmt.Fprintln(w, "No snippets found")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tAUTHOR\tCREATED\tTAGS")
+	for _, s := range snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Title, s.Author, s.Created.Local().Format("2006-01-02 15:04"), strings.Join(s.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+// Prints v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+// Parses a lifetime like "12h", "7d", "3m" or "never" into the amount and
+// unit the API expects.
+func parseExpires(s string) (int, string, error) {
+	if s == "never" {
+		return 0, "never", nil
+	}
+
+	units := map[string]string{"h": "hours", "d": "days", "m": "months"}
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1:]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err == nil && n > 0 {
+				return n, unit, nil
+			}
+		}
+	}
+
+	return 0, "", fmt.Errorf("invalid lifetime %q, use something like 12h, 7d, 3m or never", s)
+}
+
+// Returns the first non-blank line of content, shortened to fit in a title
+func firstLine(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > 100 {
+			line = string([]rune(line)[:97]) + "..."
+		}
+		return line
+	}
+	return "Untitled"
+}
+
+// Picks the language for a file from its extension, "" (plain text) if it
+// isn't one the server knows
+func languageForFile(name string) string {
+	if strings.EqualFold(filepath.Base(name), "Dockerfile") {
+		return "docker"
+	}
+
+	languages := map[string]string{
+		".sh": "bash", ".bash": "bash", ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp",
+		".css": "css", ".diff": "diff", ".patch": "diff", ".go": "go", ".html": "html",
+		".ini": "ini", ".java": "java", ".js": "javascript", ".json": "json", ".php": "php",
+		".ps1": "powershell", ".py": "python", ".rb": "ruby", ".rs": "rust", ".sql": "sql",
+		".tf": "terraform", ".toml": "toml", ".ts": "typescript", ".yaml": "yaml", ".yml": "yaml",
+	}
+	return languages[strings.ToLower(filepath.Ext(name))]
+}
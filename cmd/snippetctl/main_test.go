@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+)
+
+// Writes a config file pointing at server and returns its path
+func writeConfig(t *testing.T, server string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"server": "`+server+`/", "token": "secret"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCreateFromStdin(t *testing.T) {
+	var got newSnippet
+	var auth string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/snippets" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"snippet": {"id": 7, "visibility": "unlisted", "slug": "abc"}}`))
+	}))
+	defer ts.Close()
+
+	stdout := new(bytes.Buffer)
+	args := []string{"-config", writeConfig(t, ts.URL), "create", "-tags", "ci, go", "-expires", "7d", "-visibility", "unlisted"}
+
+	err := run(args, strings.NewReader("\n  FAIL: TestFoo\nmore output\n"), stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, auth, "Bearer secret")
+	assert.Equal(t, got.Title, "FAIL: TestFoo")
+	assert.Equal(t, strings.Join(got.Tags, ","), "ci,go")
+	assert.Equal(t, got.Expires, 7)
+	assert.Equal(t, got.ExpiresUnit, "days")
+	assert.Equal(t, stdout.String(), ts.URL+"/s/abc\n")
+}
+
+func TestValidationErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"errors": {"title": "This field cannot be blank", "content": "This field cannot be blank"}}`))
+	}))
+	defer ts.Close()
+
+	err := run([]string{"-config", writeConfig(t, ts.URL), "create"}, strings.NewReader(""), new(bytes.Buffer))
+
+	assert.Equal(t, err.Error(), "invalid snippet: content: This field cannot be blank; title: This field cannot be blank")
+}
+
+func TestParseExpires(t *testing.T) {
+	tests := []struct {
+		input   string
+		amount  int
+		unit    string
+		wantErr bool
+	}{
+		{input: "12h", amount: 12, unit: "hours"},
+		{input: "7d", amount: 7, unit: "days"},
+		{input: "3m", amount: 3, unit: "months"},
+		{input: "never", unit: "never"},
+		{input: "0d", wantErr: true},
+		{input: "d", wantErr: true},
+		{input: "2w", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			amount, unit, err := parseExpires(tt.input)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, amount, tt.amount)
+			assert.Equal(t, unit, tt.unit)
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// A snippet as the API returns it
+type snippetResponse struct {
+	ID                int        `json:"id"`
+	Title             string     `json:"title"`
+	Content           string     `json:"content,omitempty"` // left out of lists
+	Language          string     `json:"language"`
+	Markdown          bool       `json:"markdown"`
+	Tags              []string   `json:"tags"`
+	Visibility        string     `json:"visibility"`
+	Slug              string     `json:"slug,omitempty"` // only shown to the author
+	AuthorID          int        `json:"author_id"`
+	Author            string     `json:"author"`
+	ParentID          int        `json:"parent_id,omitempty"`
+	Forks             int        `json:"forks"`
+	Views             int        `json:"views"`
+	MaxViews          int        `json:"max_views"`
+	PasswordProtected bool       `json:"password_protected"`
+	Created           time.Time  `json:"created"`
+	Expires           *time.Time `json:"expires"` // null if the snippet never expires
+}
+
+func (app *application) snippetResponse(r *http.Request, s *models.Snippet) snippetResponse {
+	res := snippetResponse{
+		ID:                s.ID,
+		Title:             s.Title,
+		Content:           s.Content,
+		Language:          s.Language,
+		Markdown:          s.Markdown,
+		Tags:              s.Tags,
+		Visibility:        s.Visibility,
+		AuthorID:          s.AuthorID,
+		Author:            s.AuthorName,
+		ParentID:          s.ParentID,
+		Forks:             s.Forks,
+		Views:             s.Views,
+		MaxViews:          s.MaxViews,
+		PasswordProtected: len(s.PasswordHash) > 0,
+		Created:           s.Created,
+	}
+	if res.Tags == nil {
+		res.Tags = []string{}
+	}
+	if app.isAuthor(r, s) {
+		res.Slug = s.Slug
+	}
+	if !s.Expires.IsZero() {
+		res.Expires = &s.Expires
+	}
+	return res
+}
+
+// A snippet as API lists return it. The content is left out, as on the HTML
+// lists: only GET /api/v1/snippets/:id hands it out, where passwords and view
+// limits are enforced.
+func (app *application) snippetListItem(r *http.Request, s *models.Snippet) snippetResponse {
+	res := app.snippetResponse(r, s)
+	res.Content = ""
+	return res
+}
+
+// Body of API requests creating or replacing a snippet. Mirrors the fields of
+// snippetCreateForm so that the same validation applies.
+type snippetRequest struct {
+	Title          string   `json:"title"`
+	Content        string   `json:"content"`
+	Language       string   `json:"language"` // defaults to plaintext
+	Markdown       bool     `json:"markdown"`
+	Tags           []string `json:"tags"`
+	Visibility     string   `json:"visibility"` // defaults to public
+	MaxViews       int      `json:"max_views"`
+	Password       string   `json:"password"`
+	RemovePassword bool     `json:"remove_password"`
+	// Lifetime counted from now. Defaults to a year when creating; when
+	// replacing a snippet the current expiry is kept unless a unit is given.
+	Expires     int    `json:"expires"`
+	ExpiresUnit string `json:"expires_unit"`
+}
+
+// Converts the request into the equivalent form, filling in defaults
+func (req *snippetRequest) form() snippetCreateForm {
+	form := snippetCreateForm{
+		Title:          req.Title,
+		Content:        req.Content,
+		Language:       req.Language,
+		Markdown:       req.Markdown,
+		Tags:           strings.Join(req.Tags, ","),
+		Visibility:     req.Visibility,
+		MaxViews:       req.MaxViews,
+		Password:       req.Password,
+		RemovePassword: req.RemovePassword,
+		Expires:        req.Expires,
+		ExpiresUnit:    req.ExpiresUnit,
+	}
+	if form.Language == "" {
+		form.Language = plainText
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+	return form
+}
+
+// Sends the field errors of a form that failed validation
+func (app *application) failedValidationJSON(w http.ResponseWriter, form snippetCreateForm) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": form.FieldErrors})
+}
+
+// GET /api/v1/snippets lists the latest public snippets a page at a time,
+// optionally only those tagged with ?tag=. Contents are left out.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	page, number := app.readPage(r)
+	tag := r.URL.Query().Get("tag")
+
+	var snippets []*models.Snippet
+	var err error
+	if tag != "" {
+		snippets, err = app.snippets.ByTag(tag, page)
+	} else {
+		snippets, err = app.snippets.Latest(page)
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippets, p := app.paginate(snippets, page, number)
+
+	res := struct {
+		Snippets []snippetResponse `json:"snippets"`
+		Newer    string            `json:"newer,omitempty"`
+		Older    string            `json:"older,omitempty"`
+	}{
+		Snippets: []snippetResponse{},
+		Newer:    apiPageURL(p.Newer, tag),
+		Older:    apiPageURL(p.Older, tag),
+	}
+	for _, s := range snippets {
+		res.Snippets = append(res.Snippets, app.snippetListItem(r, s))
+	}
+
+	app.writeJSON(w, http.StatusOK, res)
+}
+
+// Turns a link built by paginate into an API URL
+func apiPageURL(query string, tag string) string {
+	if query == "" {
+		return ""
+	}
+	if tag != "" {
+		query += "&tag=" + url.QueryEscape(tag)
+	}
+	return "/api/v1/snippets" + query
+}
+
+// GET /api/v1/search?q= searches the public snippets like the search page,
+// a page (?page=) at a time. Contents are left out.
+func (app *application) apiSnippetSearch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	query := strings.TrimSpace(qs.Get("q"))
+	number := max(readInt(qs, "page"), 1)
+
+	if query == "" {
+		app.errorJSON(w, http.StatusBadRequest, "missing search query (?q=)")
+		return
+	}
+
+	// Ask for one extra result to find out if there is another page
+	snippets, err := app.snippets.Search(query, app.pageSize+1, (number-1)*app.pageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	res := struct {
+		Snippets []snippetResponse `json:"snippets"`
+		Newer    string            `json:"newer,omitempty"`
+		Older    string            `json:"older,omitempty"`
+	}{
+		Snippets: []snippetResponse{},
+	}
+	if number > 1 {
+		res.Newer = apiSearchURL(query, number-1)
+	}
+	if len(snippets) > app.pageSize {
+		snippets = snippets[:app.pageSize]
+		res.Older = apiSearchURL(query, number+1)
+	}
+	for _, s := range snippets {
+		res.Snippets = append(res.Snippets, app.snippetListItem(r, s))
+	}
+
+	app.writeJSON(w, http.StatusOK, res)
+}
+
+// Builds the API URL of a page of search results
+func apiSearchURL(query string, number int) string {
+	qs := url.Values{}
+	qs.Set("q", query)
+	qs.Set("page", strconv.Itoa(number))
+	return "/api/v1/search?" + qs.Encode()
+}
+
+// GET /api/v1/snippets/:id returns a snippet the caller may see. As on the
+// view page this counts as a view unless the caller wrote the snippet.
+// Password protected snippets can only be unlocked in the browser.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippets.Peek(readIntParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	if !app.canView(r, snippet, false) {
+		app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	if !app.isAuthor(r, snippet) {
+		if len(snippet.PasswordHash) > 0 {
+			app.errorJSON(w, http.StatusForbidden, "snippet is password protected")
+			return
+		}
+
+		snippet, err = app.snippets.Get(snippet.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.errorJSON(w, http.StatusNotFound, "snippet not found")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": app.snippetResponse(r, snippet)})
+}
+
+// POST /api/v1/snippets creates a snippet owned by the caller
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var req snippetRequest
+
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := req.form()
+	if form.ExpiresUnit == "" {
+		form.Expires, form.ExpiresUnit = app.defaultExpiry()
+	}
+
+	form.validate()
+	expires := app.checkExpiry(&form.Validator, time.Now(), form.Expires, form.ExpiresUnit)
+
+	if !form.Valid() {
+		app.failedValidationJSON(w, form)
+		return
+	}
+
+	snippet := form.snippet()
+	snippet.Expires = expires
+	snippet.AuthorID = app.authenticatedUserID(r)
+
+	err = snippet.SetPassword(form.Password)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	id, err := app.snippets.Insert(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet, err = app.snippets.Peek(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": app.snippetResponse(r, snippet)})
+}
+
+// PUT /api/v1/snippets/:id replaces a snippet owned by the caller. Like the
+// edit form, a blank password keeps the current one.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var req snippetRequest
+
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := req.form()
+	form.validate()
+
+	expires := snippet.Expires
+	if form.ExpiresUnit != "" {
+		expires = app.checkExpiry(&form.Validator, time.Now(), form.Expires, form.ExpiresUnit)
+	}
+
+	if !form.Valid() {
+		app.failedValidationJSON(w, form)
+		return
+	}
+
+	updated := form.snippet()
+	updated.ID = snippet.ID
+	updated.Expires = expires
+
+	updated.PasswordHash = snippet.PasswordHash
+	if form.RemovePassword {
+		updated.PasswordHash = nil
+	}
+	if form.Password != "" {
+		err = updated.SetPassword(form.Password)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+	}
+
+	err = app.snippets.Update(updated)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	updated, err = app.snippets.Peek(snippet.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": app.snippetResponse(r, updated)})
+}
+
+// DELETE /api/v1/snippets/:id removes a snippet owned by the caller
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The API counterpart of ownedSnippet
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippets.Peek(readIntParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, snippet, false) {
+		app.errorJSON(w, http.StatusNotFound, "snippet not found")
+		return nil, false
+	}
+
+	if snippet.AuthorID != app.authenticatedUserID(r) {
+		app.errorJSON(w, http.StatusForbidden, "snippet belongs to another user")
+		return nil, false
+	}
+
+	return snippet, true
+}
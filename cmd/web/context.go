@@ -5,4 +5,13 @@ package main
 // for more common keys s.a "isAuthenticated"
 type contextKey string 
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
\ No newline at end of file
+const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// Id of the authenticated user, set next to isAuthenticatedContextKey. Kept in
+// the context rather than read from the session so that API requests, which
+// don't have a session, work the same way.
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// Scope (models.ScopeRead or models.ScopeWrite) an API request was
+// authenticated with
+const apiScopeContextKey = contextKey("apiScope")
\ No newline at end of file
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/validators"
+)
+
+// Units a snippet's lifetime can be given in
+const (
+	expiresHours  = "hours"
+	expiresDays   = "days"
+	expiresMonths = "months"
+	expiresNever  = "never" // the amount is ignored
+)
+
+// Offered by the unit pickers, in order
+var expiryUnits = []string{expiresHours, expiresDays, expiresMonths, expiresNever}
+
+// Shortest time one of each unit can stand for: a day loses an hour when the
+// clocks go forward and February has 28 days
+var shortestUnit = map[string]time.Duration{
+	expiresHours:  time.Hour,
+	expiresDays:   23 * time.Hour,
+	expiresMonths: 28*24*time.Hour - time.Hour,
+}
+
+// Returns the time at which a snippet kept for amount units starting at from
+// expires, or the zero time if it never does. Months are calendar months.
+func expiryTime(from time.Time, amount int, unit string) time.Time {
+	switch unit {
+	case expiresHours:
+		return from.Add(time.Duration(amount) * time.Hour)
+	case expiresDays:
+		return from.AddDate(0, 0, amount)
+	case expiresMonths:
+		return from.AddDate(0, amount, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// Checks a lifetime picked on a form and returns the expiry time it works out
+// to when counted from from. Field errors are added to v under "expires". No
+// snippet may be kept for more than app.maxExpiry from now, unless it never
+// expires.
+func (app *application) checkExpiry(v *validators.Validator, from time.Time, amount int, unit string) time.Time {
+	v.CheckField(validators.PermittedValue(unit, expiryUnits...), "expires", "Pick one of the listed units")
+	if unit == expiresNever || !v.Valid() {
+		return time.Time{}
+	}
+
+	tooLong := fmt.Sprintf("Snippets can't be kept for more than %s", humanDuration(app.maxExpiry))
+	v.CheckField(amount > 0, "expires", "Value must be at least 1")
+	// Amounts which can't possibly fit are turned away before expiryTime adds
+	// them up: a large enough one overflows and wraps around into the past
+	v.CheckField(amount <= int(app.maxExpiry/shortestUnit[unit]), "expires", tooLong)
+	if !v.Valid() {
+		return time.Time{}
+	}
+
+	expires := expiryTime(from, amount, unit)
+	v.CheckField(!expires.After(time.Now().Add(app.maxExpiry)), "expires", tooLong)
+
+	return expires
+}
+
+// Lifetime the create and renew forms start out with: a year, or as long as
+// allowed if that is less.
+func (app *application) defaultExpiry() (int, string) {
+	days := int(app.maxExpiry / (24 * time.Hour))
+	switch {
+	case days >= 365:
+		return 365, expiresDays
+	case days > 0:
+		return days, expiresDays
+	default:
+		return max(int(app.maxExpiry/time.Hour), 1), expiresHours
+	}
+}
+
+// Formats d in whole days, or whole hours if it is less than two days
+func humanDuration(d time.Duration) string {
+	if d < 48*time.Hour {
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+}
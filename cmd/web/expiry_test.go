@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/validators"
+)
+
+func TestExpiryTime(t *testing.T) {
+	from := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		amount int
+		unit   string
+		want   time.Time
+	}{
+		{name: "Hours", amount: 36, unit: expiresHours, want: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "Days", amount: 30, unit: expiresDays, want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "Months", amount: 2, unit: expiresMonths, want: time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{name: "Never", amount: 5, unit: expiresNever, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, expiryTime(from, tt.amount, tt.unit), tt.want)
+		})
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	app := &application{maxExpiry: 7 * 24 * time.Hour}
+
+	tests := []struct {
+		name   string
+		amount int
+		unit   string
+		valid  bool
+	}{
+		{name: "Within the maximum", amount: 7, unit: expiresDays, valid: true},
+		{name: "Beyond the maximum", amount: 8, unit: expiresDays, valid: false},
+		{name: "Never", amount: 0, unit: expiresNever, valid: true},
+		{name: "Zero", amount: 0, unit: expiresHours, valid: false},
+		{name: "Unknown unit", amount: 1, unit: "weeks", valid: false},
+		// Would overflow time.Duration and come out in the past
+		{name: "Overflowing hours", amount: 3000000, unit: expiresHours, valid: false},
+		{name: "Huge days", amount: math.MaxInt, unit: expiresDays, valid: false},
+		{name: "Huge months", amount: math.MaxInt, unit: expiresMonths, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validators.Validator
+			app.checkExpiry(&v, time.Now(), tt.amount, tt.unit)
+
+			assert.Equal(t, v.Valid(), tt.valid)
+		})
+	}
+}
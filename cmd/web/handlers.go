@@ -3,9 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
+	"snippetbox.victorsmith.dev/internal/diff"
 	"snippetbox.victorsmith.dev/internal/models"
 	"snippetbox.victorsmith.dev/internal/validators"
 
@@ -15,10 +18,38 @@ import (
 type snippetCreateForm struct {
 	Title                string     `form:"title"`
 	Content              string     `form:"content"`
-	Expires              int        `form:"expires"`
+	Language             string     `form:"language"`
+	Markdown             bool       `form:"markdown"`
+	Expires              int        `form:"expires"`      // amount of ExpiresUnit, only used when creating
+	ExpiresUnit          string     `form:"expires_unit"` // one of the expires* units
+	Tags                 string     `form:"tags"`         // comma separated
+	Visibility           string     `form:"visibility"`
+	MaxViews             int        `form:"max_views"`       // 0 for no limit
+	Password             string     `form:"password"`        // blank leaves the current password alone when editing
+	RemovePassword       bool       `form:"remove_password"` // only offered when editing
 	validators.Validator `form:"-"` // tells the decoder to completely ignore a field during decoding.
 }
 
+type snippetRenewForm struct {
+	Expires              int    `form:"expires"`
+	ExpiresUnit          string `form:"expires_unit"`
+	validators.Validator `form:"-"`
+}
+
+type snippetUnlockForm struct {
+	Password             string `form:"password"`
+	validators.Validator `form:"-"`
+}
+
+// Limits on the tags attached to a snippet
+const (
+	maxTags      = 5
+	maxTagLength = 32
+)
+
+// Highest view limit which can be set on a snippet
+const maxViewLimit = 1000
+
 type userSignupForm struct {
 	Name                 string `form:"name"`
 	Email                string `form:"email"`
@@ -26,37 +57,314 @@ type userSignupForm struct {
 	validators.Validator `form:"-"`
 }
 
+type tokenCreateForm struct {
+	Name                 string `form:"name"`
+	Scope                string `form:"scope"`
+	validators.Validator `form:"-"`
+}
+
 type userLoginForm struct {
 	Email                string `form:"email"`
 	Password             string `form:"password"`
 	validators.Validator `form:"-"`
 }
 
+// Validate errors
+// 1) Check that the title and content fields are not empty.
+// 2) Check that the title field is not more than 100 characters long.
+// 3) Check that the language is one offered by the picker.
+// 4) Check that there aren't too many tags and that each one is a short lowercase word.
+// 5) Check that the visibility is public, unlisted or private.
+// 6) Check that a new password, if there is one, is at least 8 characters long.
+// 7) Check that the view limit is between 0 (no limit) and maxViewLimit.
+// Shared by the create and edit handlers. The expiry is only picked when
+// creating a snippet, so it is checked by snippetCreatePost.
+func (form *snippetCreateForm) validate() {
+	// Embedding of validators.Validator allows for a direct call to the Validator method(s)
+	form.CheckField(validators.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validators.MaxChars(form.Title, 100), "title", "this field cannot be 100 chars long")
+	form.CheckField(validators.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validators.PermittedValue(form.Language, languageNames()...), "language", "Pick one of the listed languages")
+
+	tags := parseTags(form.Tags)
+	form.CheckField(validators.MaxItems(tags, maxTags), "tags", fmt.Sprintf("No more than %d tags are allowed", maxTags))
+	form.CheckField(validators.AllMatch(tags, validators.TagRegexp), "tags", "Tags may only contain lowercase letters, digits and dashes")
+	for _, tag := range tags {
+		form.CheckField(validators.MaxChars(tag, maxTagLength), "tags", fmt.Sprintf("Tags cannot be more than %d chars long", maxTagLength))
+	}
+
+	form.CheckField(validators.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "Value must be public, unlisted or private")
+
+	if form.Password != "" {
+		form.CheckField(validators.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	}
+
+	form.CheckField(validators.InRange(form.MaxViews, 0, maxViewLimit), "max_views", fmt.Sprintf("Value must be between 0 and %d", maxViewLimit))
+}
+
+// Builds the snippet described by a (valid) form
+func (form *snippetCreateForm) snippet() *models.Snippet {
+	return &models.Snippet{
+		Title:      form.Title,
+		Content:    form.Content,
+		Language:   form.Language,
+		Markdown:   form.Markdown,
+		Tags:       parseTags(form.Tags),
+		Visibility: form.Visibility,
+		MaxViews:   form.MaxViews,
+	}
+}
+
 // Make the home handler a method for the application struct to introduce dependency injection?
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	page, number := app.readPage(r)
+
+	snippets, err := app.snippets.Latest(page)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
 	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	data.Snippets, data.Pagination = app.paginate(snippets, page, number)
 	app.render(w, data, http.StatusOK, "home.html")
 }
 
 // Returns page containing detials of snippet with :id
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// The :id param is passed via the request context
+	snippet, ok := app.visibleSnippet(w, r, readIntParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	app.showSnippet(w, r, snippet)
+}
+
+// Returns page containing details of the snippet with the share link :slug.
+// Unlike /snippet/view/:id this also works for unlisted snippets.
+func (app *application) snippetShare(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
+	snippet, err := app.snippets.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !app.canView(r, snippet, true) {
 		app.notFound(w)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	app.showSnippet(w, r, snippet)
+}
+
+// Renders the view page for a snippet the current user is allowed to see, or
+// the unlock form if it is protected by a password. Everybody but the author
+// uses up one view. The history is only listed when the revision pages are
+// reachable too, which isn't the case for unlisted snippets opened through
+// their share link or for snippets with a view limit.
+func (app *application) showSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	if app.isLocked(r, snippet) {
+		data.Form = snippetUnlockForm{}
+		app.render(w, data, http.StatusOK, "unlock.html")
+		return
+	}
+
+	snippet, ok := app.countView(w, r, snippet)
+	if !ok {
+		return
+	}
+	data.Snippet = snippet
+
+	// The author gets to renew the snippet from the view page
+	if app.isAuthor(r, snippet) {
+		expires, unit := app.defaultExpiry()
+		data.Form = snippetRenewForm{Expires: expires, ExpiresUnit: unit}
+	}
+
+	app.renderSnippet(w, r, data, http.StatusOK)
+}
+
+// Returns page containing just the content of the snippet with :id (or the
+// share link :slug) as plain text, for piping into other tools.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeRaw(w, snippet)
+}
+
+// Like snippetRaw, but has the browser save the snippet as a file named after
+// its title and language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": downloadName(snippet)}))
+	app.writeRaw(w, snippet)
+}
+
+// Renders the view page for data.Snippet, adding its history if the current
+// user may browse it.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, data *templateData, status int) {
+	snippet := data.Snippet
+
+	if app.canView(r, snippet, false) && (snippet.MaxViews == 0 || app.isAuthor(r, snippet)) {
+		revisions, err := app.snippets.Revisions(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Revisions = revisions
+	}
+
+	// We render the individual snippers under the view template
+	app.render(w, data, status, "view.html")
+}
+
+// Checks the password of the snippet with the share link :slug and remembers
+// in the session that it has been unlocked. The unlock form posts here for
+// every snippet because unlisted ones can't be opened by id.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.snippets.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !app.canView(r, snippet, true) {
+		app.notFound(w)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err = app.decodePostError(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validators.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() && len(snippet.PasswordHash) > 0 {
+		ok, err := snippet.PasswordMatches(form.Password)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !ok {
+			form.AddNonFieldError("Password is incorrect")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, data, http.StatusUnprocessableEntity, "unlock.html")
+		return
+	}
+
+	app.unlock(r, snippet)
+
+	// Send the user back to wherever they can open the snippet from
+	if app.canView(r, snippet, false) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, "/s/"+snippet.Slug, http.StatusSeeOther)
+	}
+}
+
+// Lists the snippets tagged with :name
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	tag := params.ByName("name")
+
+	page, number := app.readPage(r)
+
+	snippets, err := app.snippets.ByTag(tag, page)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets, data.Pagination = app.paginate(snippets, page, number)
+	app.render(w, data, http.StatusOK, "tag.html")
+}
+
+// Lists the snippets matching the ?q= query param
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	query := strings.TrimSpace(qs.Get("q"))
+	number := max(readInt(qs, "page"), 1)
+
+	data := app.newTemplateData(r)
+	data.Query = query
+
+	// Nothing to search for => just show the search box
+	if query == "" {
+		app.render(w, data, http.StatusOK, "search.html")
+		return
+	}
+
+	// Ask for one extra result to find out if there is another page
+	snippets, err := app.snippets.Search(query, app.pageSize+1, (number-1)*app.pageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Pagination = pagination{Number: number}
+	if number > 1 {
+		data.Pagination.Newer = searchURL(query, number-1)
+	}
+	if len(snippets) > app.pageSize {
+		snippets = snippets[:app.pageSize]
+		data.Pagination.Older = searchURL(query, number+1)
+	}
+	data.Snippets = snippets
+
+	app.render(w, data, http.StatusOK, "search.html")
+}
+
+// Returns page showing revision :n of the snippet with :id
+func (app *application) snippetRevision(w http.ResponseWriter, r *http.Request) {
+	n := readIntParam(r, "n")
+	if n < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Look the snippet up first so the history of an expired (or hidden)
+	// snippet goes with it
+	snippet, ok := app.unlockedSnippet(w, r, readIntParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	revision, err := app.snippets.Revision(snippet.ID, n)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -68,9 +376,98 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Revision = revision
+	app.render(w, data, http.StatusOK, "revision.html")
+}
 
-	// We render the individual snippers under the view template
-	app.render(w, data, http.StatusOK, "view.html")
+// Number of unchanged lines shown around each change on the diff pages
+const diffContext = 3
+
+// Returns page comparing the content of the snippets with ids ?a= and ?b=
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var sides [2]*models.Snippet
+	for i, key := range []string{"a", "b"} {
+		id := readInt(qs, key)
+		if id < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		snippet, ok := app.unlockedSnippet(w, r, id)
+		if !ok {
+			return
+		}
+		sides[i] = snippet
+	}
+
+	a, b := sides[0], sides[1]
+
+	data := app.newTemplateData(r)
+	data.Diff = &diffView{
+		From:  diffSide{Title: fmt.Sprintf("#%d %s", a.ID, a.Title), URL: fmt.Sprintf("/snippet/view/%d", a.ID)},
+		To:    diffSide{Title: fmt.Sprintf("#%d %s", b.ID, b.Title), URL: fmt.Sprintf("/snippet/view/%d", b.ID)},
+		Hunks: diff.Compute(a.Content, b.Content, diffContext),
+	}
+	app.render(w, data, http.StatusOK, "diff.html")
+}
+
+// Returns page comparing revisions ?from= and ?to= of the snippet with :id.
+// By default the latest revision is compared with the one before it.
+func (app *application) snippetRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	// Look the snippet up first so the history of an expired (or hidden)
+	// snippet goes with it
+	snippet, ok := app.unlockedSnippet(w, r, readIntParam(r, "id"))
+	if !ok {
+		return
+	}
+	id := snippet.ID
+
+	revisions, err := app.snippets.Revisions(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	// Revisions are listed newest first
+	qs := r.URL.Query()
+	to := readInt(qs, "to")
+	if to == 0 {
+		to = revisions[0].Number
+	}
+	from := readInt(qs, "from")
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	var sides [2]*models.Revision
+	for i, n := range []int{from, to} {
+		for _, rev := range revisions {
+			if rev.Number == n {
+				sides[i] = rev
+			}
+		}
+		if sides[i] == nil {
+			app.notFound(w)
+			return
+		}
+	}
+
+	a, b := sides[0], sides[1]
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = &diffView{
+		From:  diffSide{Title: fmt.Sprintf("Revision %d", a.Number), URL: fmt.Sprintf("/snippet/view/%d/rev/%d", id, a.Number)},
+		To:    diffSide{Title: fmt.Sprintf("Revision %d", b.Number), URL: fmt.Sprintf("/snippet/view/%d/rev/%d", id, b.Number)},
+		Hunks: diff.Compute(a.Content, b.Content, diffContext),
+	}
+	app.render(w, data, http.StatusOK, "diff.html")
 }
 
 // Fetch form page
@@ -80,9 +477,13 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// Initialize a new createSnippetForm instance and pass it to the template.
 	// Notice how this is also a great opportunity to set any default or
 	// 'initial' values for the form --- here we set the initial value for the
-	// snippet expiry to 365 days.
+	// snippet expiry to a year (or as long as allowed).
+	expires, unit := app.defaultExpiry()
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:     expires,
+		ExpiresUnit: unit,
+		Language:    plainText,
+		Visibility:  models.VisibilityPublic,
 	}
 
 	app.render(w, data, http.StatusOK, "create.html")
@@ -100,16 +501,8 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Validate errors
-	// 1) Check that the title and content fields are not empty.
-	// 2) Check that the title field is not more than 100 characters long.
-	// 3) Check that the expires value exactly matches one of our permitted values ( 1 , 7 or 365 days).
-
-	// Embedding of validators.Validator allows for a direct call to the Validator method(s)
-	form.CheckField(validators.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validators.MaxChars(form.Title, 100), "title", "this field cannot be 100 chars long")
-	form.CheckField(validators.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validators.PermittedValue(form.Expires, 1, 7, 365), "expires", "Value must be 1, 7 or 365")
+	form.validate()
+	expires := app.checkExpiry(&form.Validator, time.Now(), form.Expires, form.ExpiresUnit)
 
 	// use the HTTP status code 422 Unprocessable Entity to indicate bad data in fomr
 	// pass the snippetCreateForm object to the template
@@ -120,7 +513,18 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	snippet := form.snippet()
+	snippet.Expires = expires
+	// requireAuthentication guarantees there is a logged in user at this point
+	snippet.AuthorID = app.authenticatedUserID(r)
+
+	err = snippet.SetPassword(form.Password)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := app.snippets.Insert(snippet)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -132,6 +536,178 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+// Fetch the edit page for a snippet owned by the current user
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	// Pre-fill the form with the current values. Editing leaves the expiry
+	// alone, that is what renewing is for.
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Markdown:   snippet.Markdown,
+		Tags:       strings.Join(snippet.Tags, ", "),
+		Visibility: snippet.Visibility,
+		MaxViews:   snippet.MaxViews,
+	}
+
+	app.render(w, data, http.StatusOK, "edit.html")
+}
+
+// Updates a snippet owned by the current user
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+
+	err := app.decodePostError(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, data, http.StatusUnprocessableEntity, "edit.html")
+		return
+	}
+
+	updated := form.snippet()
+	updated.ID = snippet.ID
+	updated.Expires = snippet.Expires
+
+	// Keep the current password unless a new one was given or it was removed
+	updated.PasswordHash = snippet.PasswordHash
+	if form.RemovePassword {
+		updated.PasswordHash = nil
+	}
+	if form.Password != "" {
+		err = updated.SetPassword(form.Password)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	err = app.snippets.Update(updated)
+	if err != nil {
+		// Somebody else may have deleted it in the meantime
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet Succesfully Updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// Extends the life of a snippet owned by the current user. The new lifetime is
+// added to the current expiry time (or to now if it was set to never expire)
+// and can't go beyond the configured maximum. Expired snippets can't be
+// renewed: like everybody else, their author gets a 404.
+func (app *application) snippetRenewPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetRenewForm
+
+	err := app.decodePostError(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	from := snippet.Expires
+	if from.IsZero() {
+		from = time.Now()
+	}
+	expires := app.checkExpiry(&form.Validator, from, form.Expires, form.ExpiresUnit)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.renderSnippet(w, r, data, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = app.snippets.Renew(snippet.ID, expires)
+	if err != nil {
+		// Somebody else may have deleted it in the meantime
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet Succesfully Renewed!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// Deletes a snippet owned by the current user
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		// Somebody else may have deleted it in the meantime
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet Succesfully Deleted!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Copies a snippet into a new one owned by the current user
+func (app *application) snippetForkPost(w http.ResponseWriter, r *http.Request) {
+	// Only snippets which can be opened by id may be forked
+	snippet, ok := app.unlockedSnippet(w, r, readIntParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	forkID, err := app.snippets.Fork(snippet.ID, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet Succesfully Forked!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", forkID), http.StatusSeeOther)
+}
+
 // Auth Handlers
 // Fetch user login page
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
@@ -264,6 +840,75 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// Lists the API tokens of the current user, with a form to create another
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{Scope: models.ScopeRead}
+	app.renderTokens(w, r, data, http.StatusOK)
+}
+
+// Creates an API token for the current user. The plaintext token is rendered
+// straight away instead of redirecting, as this is the only time it is shown.
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostError(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validators.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validators.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(validators.PermittedValue(form.Scope, models.ScopeRead, models.ScopeWrite), "scope", "Value must be read or write")
+
+	data := app.newTemplateData(r)
+
+	if !form.Valid() {
+		data.Form = form
+		app.renderTokens(w, r, data, http.StatusUnprocessableEntity)
+		return
+	}
+
+	data.NewToken, err = app.tokens.Insert(app.authenticatedUserID(r), form.Name, form.Scope)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Form = tokenCreateForm{Scope: models.ScopeRead}
+	app.renderTokens(w, r, data, http.StatusOK)
+}
+
+// Revokes one of the current user's API tokens
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	err := app.tokens.Delete(readIntParam(r, "id"), app.authenticatedUserID(r))
+	if err != nil {
+		// Also the answer for other users' tokens
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token Succesfully Revoked!")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// Renders the tokens page with the current user's tokens added to data
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, data *templateData, status int) {
+	tokens, err := app.tokens.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Tokens = tokens
+
+	app.render(w, data, status, "tokens.html")
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Ok"))
 }
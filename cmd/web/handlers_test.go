@@ -2,12 +2,19 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/models"
+	"snippetbox.victorsmith.dev/internal/models/mocks"
 )
 
 func TestPing (t *testing.T) {
@@ -44,3 +51,705 @@ func TestPing (t *testing.T) {
 
 	assert.Equal(t, string(body), "Ok")
 }
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Valid ID", urlPath: "/snippet/view/1", wantCode: http.StatusOK, wantBody: "An old silent pond..."},
+		{name: "Non-existent ID", urlPath: "/snippet/view/2", wantCode: http.StatusNotFound},
+		{name: "Negative ID", urlPath: "/snippet/view/-1", wantCode: http.StatusNotFound},
+		{name: "Decimal ID", urlPath: "/snippet/view/1.23", wantCode: http.StatusNotFound},
+		{name: "String ID", urlPath: "/snippet/view/foo", wantCode: http.StatusNotFound},
+		{name: "Empty ID", urlPath: "/snippet/view/", wantCode: http.StatusNotFound},
+		{name: "Share link", urlPath: "/s/mock-slug", wantCode: http.StatusOK, wantBody: "An old silent pond..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "Bob"
+		validPassword = "validPa$$word"
+		validEmail    = "bob@example.com"
+		formTag       = "<form action='/user/signup' method='POST' novalidate>"
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+		wantLocation string
+	}{
+		{name: "Valid submission", userName: validName, userEmail: validEmail, userPassword: validPassword, csrfToken: validCSRFToken, wantCode: http.StatusSeeOther, wantLocation: "/user/login"},
+		{name: "Invalid CSRF Token", userName: validName, userEmail: validEmail, userPassword: validPassword, csrfToken: "wrongToken", wantCode: http.StatusBadRequest},
+		{name: "Empty name", userEmail: validEmail, userPassword: validPassword, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: formTag},
+		{name: "Empty email", userName: validName, userPassword: validPassword, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: formTag},
+		{name: "Empty password", userName: validName, userEmail: validEmail, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: formTag},
+		{name: "Invalid email", userName: validName, userEmail: "bob@example.", userPassword: validPassword, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: formTag},
+		{name: "Short password", userName: validName, userEmail: validEmail, userPassword: "pa$$", csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: formTag},
+		{name: "Duplicate email", userName: validName, userEmail: mocks.DuplicateEmail, userPassword: validPassword, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantFormTag: "Email already in use"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestUserLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		email        string
+		password     string
+		csrfToken    string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{name: "Wrong password", email: mocks.UserEmail, password: "wrongPa$$word", csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantBody: "Email or Password is incorrect"},
+		{name: "Unknown email", email: "nobody@example.com", password: mocks.UserPassword, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantBody: "Email or Password is incorrect"},
+		{name: "Empty password", email: mocks.UserEmail, csrfToken: validCSRFToken, wantCode: http.StatusUnprocessableEntity, wantBody: "This field cannot be blank"},
+		{name: "Invalid CSRF Token", email: mocks.UserEmail, password: mocks.UserPassword, csrfToken: "wrongToken", wantCode: http.StatusBadRequest},
+		// Last, since it logs the client in
+		{name: "Valid credentials", email: mocks.UserEmail, password: mocks.UserPassword, csrfToken: validCSRFToken, wantCode: http.StatusSeeOther, wantLocation: "/snippet/create"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, body := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The session now belongs to the mock user: protected pages open and
+	// logging out sends them home
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, header, _ := ts.postForm(t, "/user/logout", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+
+	code, header, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	csrfToken := ts.login(t, mocks.UserEmail, mocks.UserPassword)
+
+	t.Run("Authenticated", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'")
+	})
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		expiresUnit  string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{name: "Valid submission", title: "O snail", content: "Climb Mount Fuji", expiresUnit: "days", wantCode: http.StatusSeeOther, wantLocation: "/snippet/view/2"},
+		{name: "Empty title", content: "Climb Mount Fuji", expiresUnit: "days", wantCode: http.StatusUnprocessableEntity, wantBody: "This field cannot be blank"},
+		{name: "Invalid expiry unit", title: "O snail", content: "Climb Mount Fuji", expiresUnit: "weeks", wantCode: http.StatusUnprocessableEntity, wantBody: "Pick one of the listed units"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("language", plainText)
+			form.Add("expires", "7")
+			form.Add("expires_unit", tt.expiresUnit)
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+var searchResultRX = regexp.MustCompile(`<span>#(\d+) by`)
+
+func TestSnippetSearchPages(t *testing.T) {
+	app := newTestApplication(t)
+	app.pageSize = 2
+	useMemoryStores(t, app)
+	ts := newTestServer(t, app.routes())
+
+	for i := 1; i <= 5; i++ {
+		_, err := app.snippets.Insert(&models.Snippet{Title: "Pool party", Content: "splash", Visibility: models.VisibilityPublic, AuthorID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		urlPath   string
+		wantIDs   string
+		wantOlder bool
+	}{
+		{urlPath: "/snippet/search?q=pool", wantIDs: "5 4", wantOlder: true},
+		{urlPath: "/snippet/search?q=pool&page=2", wantIDs: "3 2", wantOlder: true},
+		{urlPath: "/snippet/search?q=pool&page=3", wantIDs: "1", wantOlder: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.urlPath, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			var ids []string
+			for _, m := range searchResultRX.FindAllStringSubmatch(body, -1) {
+				ids = append(ids, m[1])
+			}
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, strings.Join(ids, " "), tt.wantIDs)
+			assert.Equal(t, strings.Contains(body, "Older &rarr;"), tt.wantOlder)
+		})
+	}
+}
+
+func TestHiddenSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	useMemoryStores(t, app)
+
+	err := app.users.Insert("Bob", "bob@example.com", "bobPa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice (user 1) writes a public snippet to diff against and two hidden ones
+	var ids = map[string]int{}
+	for _, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate} {
+		ids[visibility], err = app.snippets.Insert(&models.Snippet{Title: "Old pond", Content: "Frog jumps in", Language: plainText, Visibility: visibility, AuthorID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routes := []struct {
+		name       string
+		method     string
+		urlPath    string // %d is replaced by the id of the hidden snippet
+		protected  bool   // only for logged in users, anonymous visitors aren't even let in
+		deletes    bool   // skipped for the author, who would delete the snippet
+		authorCode int    // what the author gets instead of a 404
+	}{
+		{name: "View", method: http.MethodGet, urlPath: "/snippet/view/%d", authorCode: http.StatusOK},
+		{name: "Revision", method: http.MethodGet, urlPath: "/snippet/view/%d/rev/1", authorCode: http.StatusOK},
+		{name: "Revision diff", method: http.MethodGet, urlPath: "/snippet/view/%d/diff", authorCode: http.StatusOK},
+		{name: "Snippet diff", method: http.MethodGet, urlPath: fmt.Sprintf("/snippet/diff?a=%d&b=%%d", ids[models.VisibilityPublic]), authorCode: http.StatusOK},
+		{name: "Raw", method: http.MethodGet, urlPath: "/snippet/raw/%d", authorCode: http.StatusOK},
+		{name: "Download", method: http.MethodGet, urlPath: "/snippet/download/%d", authorCode: http.StatusOK},
+		{name: "Fork", method: http.MethodPost, urlPath: "/snippet/fork/%d", protected: true, authorCode: http.StatusSeeOther},
+		{name: "Edit form", method: http.MethodGet, urlPath: "/snippet/edit/%d", protected: true, authorCode: http.StatusOK},
+		// The empty forms fail validation, but only once the snippet was found
+		{name: "Edit", method: http.MethodPost, urlPath: "/snippet/edit/%d", protected: true, authorCode: http.StatusUnprocessableEntity},
+		{name: "Renew", method: http.MethodPost, urlPath: "/snippet/renew/%d", protected: true, authorCode: http.StatusUnprocessableEntity},
+		{name: "Delete", method: http.MethodPost, urlPath: "/snippet/delete/%d", protected: true, deletes: true},
+		{name: "API get", method: http.MethodGet, urlPath: "/api/v1/snippets/%d", authorCode: http.StatusOK},
+		{name: "API update", method: http.MethodPut, urlPath: "/api/v1/snippets/%d", protected: true, authorCode: http.StatusUnprocessableEntity},
+		{name: "API delete", method: http.MethodDelete, urlPath: "/api/v1/snippets/%d", protected: true, deletes: true},
+	}
+
+	viewers := []struct {
+		name     string
+		email    string // blank for an anonymous visitor
+		password string
+		userID   int
+		isAuthor bool
+	}{
+		{name: "Anonymous"},
+		{name: "Other user", email: "bob@example.com", password: "bobPa$$word", userID: 2},
+		{name: "Author", email: mocks.UserEmail, password: mocks.UserPassword, userID: 1, isAuthor: true},
+	}
+
+	for _, v := range viewers {
+		ts := newTestServer(t, app.routes())
+		csrfToken, apiToken := "", ""
+		if v.email != "" {
+			csrfToken = ts.login(t, v.email, v.password)
+			apiToken, err = app.tokens.Insert(v.userID, "test", models.ScopeWrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, visibility := range []string{models.VisibilityUnlisted, models.VisibilityPrivate} {
+			for _, rt := range routes {
+				if (rt.protected && v.email == "") || (rt.deletes && v.isAuthor) {
+					continue
+				}
+				urlPath := fmt.Sprintf(rt.urlPath, ids[visibility])
+
+				t.Run(fmt.Sprintf("%s/%s/%s", v.name, visibility, rt.name), func(t *testing.T) {
+					var code int
+					switch {
+					case strings.HasPrefix(urlPath, "/api/"):
+						// The API ignores the session, so send a token
+						// instead
+						req, err := http.NewRequest(rt.method, ts.URL+urlPath, strings.NewReader("{}"))
+						if err != nil {
+							t.Fatal(err)
+						}
+						if v.email != "" {
+							req.Header.Set("Authorization", "Bearer "+apiToken)
+						}
+						rs, err := ts.Client().Do(req)
+						if err != nil {
+							t.Fatal(err)
+						}
+						code, _, _ = readResponse(t, rs)
+					case rt.method == http.MethodPost:
+						form := url.Values{}
+						form.Add("csrf_token", csrfToken)
+						code, _, _ = ts.postForm(t, urlPath, form)
+					default:
+						code, _, _ = ts.get(t, urlPath)
+					}
+
+					if v.isAuthor {
+						assert.Equal(t, code, rt.authorCode)
+					} else {
+						assert.Equal(t, code, http.StatusNotFound)
+					}
+				})
+			}
+		}
+	}
+
+	// Public snippets are no secret, so changing someone else's is forbidden
+	// rather than not found
+	t.Run("Other user/public/Edit form", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		ts.login(t, "bob@example.com", "bobPa$$word")
+
+		code, _, _ := ts.get(t, fmt.Sprintf("/snippet/edit/%d", ids[models.VisibilityPublic]))
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	useMemoryStores(t, app)
+
+	const password = "unlockPa$$"
+	snippet := &models.Snippet{Title: "Old pond", Content: "Frog jumps in", Language: plainText, Visibility: models.VisibilityPublic, AuthorID: 1}
+	err := snippet.SetPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.snippets.Insert(snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippet, _ = app.snippets.Peek(id)
+
+	viewPath := fmt.Sprintf("/snippet/view/%d", id)
+	rawPath := fmt.Sprintf("/snippet/raw/%d", id)
+	unlockPath := fmt.Sprintf("/s/%s/unlock", snippet.Slug)
+
+	ts := newTestServer(t, app.routes())
+
+	// Locked: the view page shows the unlock form instead of the content, and
+	// the raw content can't be fetched around it
+	code, _, body := ts.get(t, viewPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is password protected.")
+	assert.Equal(t, strings.Contains(body, "Frog jumps in"), false)
+
+	code, header, _ := ts.get(t, rawPath)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), viewPath)
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		password     string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{name: "Blank password", wantCode: http.StatusUnprocessableEntity, wantBody: "This field cannot be blank"},
+		{name: "Wrong password", password: "wrongPa$$", wantCode: http.StatusUnprocessableEntity, wantBody: "Password is incorrect"},
+		{name: "Right password", password: password, wantCode: http.StatusSeeOther, wantLocation: viewPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, unlockPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				assert.Equal(t, strings.Contains(body, "Frog jumps in"), false)
+			}
+		})
+	}
+
+	// The session remembers the unlock
+	t.Run("Unlocked", func(t *testing.T) {
+		code, _, body := ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Frog jumps in")
+
+		code, _, body = ts.get(t, rawPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "Frog jumps in")
+	})
+
+	// Other visitors still have to enter the password
+	t.Run("Other session", func(t *testing.T) {
+		code, _, body := newTestServer(t, app.routes()).get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "This snippet is password protected.")
+	})
+
+	// The author never has to
+	t.Run("Author", func(t *testing.T) {
+		author := newTestServer(t, app.routes())
+		author.login(t, mocks.UserEmail, mocks.UserPassword)
+
+		code, _, body := author.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Frog jumps in")
+	})
+
+	// Changing the password locks the snippet again
+	t.Run("New password", func(t *testing.T) {
+		err := snippet.SetPassword("otherPa$$")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = app.snippets.Update(snippet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _, body := ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "This snippet is password protected.")
+	})
+}
+
+func TestSnippetViewLimit(t *testing.T) {
+	app := newTestApplication(t)
+	useMemoryStores(t, app)
+
+	insert := func(maxViews int) string {
+		id, err := app.snippets.Insert(&models.Snippet{Title: "Old pond", Content: "Frog jumps in", Language: plainText, Visibility: models.VisibilityPublic, AuthorID: 1, MaxViews: maxViews})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("/snippet/view/%d", id)
+	}
+
+	t.Run("Burn after reading", func(t *testing.T) {
+		viewPath := insert(1)
+		ts := newTestServer(t, app.routes())
+
+		code, _, body := ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Frog jumps in")
+
+		// Gone for everyone after the one view, the raw content included
+		code, _, _ = ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusNotFound)
+		code, _, _ = newTestServer(t, app.routes()).get(t, strings.Replace(viewPath, "view", "raw", 1))
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Author views", func(t *testing.T) {
+		viewPath := insert(2)
+		author := newTestServer(t, app.routes())
+		author.login(t, mocks.UserEmail, mocks.UserPassword)
+
+		for i := 0; i < 3; i++ {
+			code, _, _ := author.get(t, viewPath)
+			assert.Equal(t, code, http.StatusOK)
+		}
+
+		// The author's views didn't count, so both views are left
+		ts := newTestServer(t, app.routes())
+		for i := 0; i < 2; i++ {
+			code, _, body := ts.get(t, viewPath)
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, "Frog jumps in")
+		}
+		code, _, _ := ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetRenew(t *testing.T) {
+	app := newTestApplication(t)
+	db := useMemoryStores(t, app)
+
+	insert := func(expires time.Time) int {
+		id, err := app.snippets.Insert(&models.Snippet{Title: "Old pond", Content: "Frog jumps in", Language: plainText, Visibility: models.VisibilityPublic, AuthorID: 1, Expires: expires})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	now := time.Now()
+	liveID, neverID, expiredID := insert(now.Add(3*time.Hour)), insert(time.Time{}), insert(now.Add(time.Hour))
+
+	// Two hours later only the last snippet has expired
+	db.Now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	ts := newTestServer(t, app.routes())
+	csrfToken := ts.login(t, mocks.UserEmail, mocks.UserPassword)
+
+	tests := []struct {
+		name        string
+		id          int
+		wantCode    int
+		wantExpires time.Time // zero to only check it is about a day from now
+	}{
+		{name: "Live", id: liveID, wantCode: http.StatusSeeOther, wantExpires: now.Add(3 * time.Hour).AddDate(0, 0, 1)},
+		{name: "Never expiring", id: neverID, wantCode: http.StatusSeeOther},
+		{name: "Expired", id: expiredID, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", "1")
+			form.Add("expires_unit", expiresDays)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, fmt.Sprintf("/snippet/renew/%d", tt.id), form)
+			assert.Equal(t, code, tt.wantCode)
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			s, err := app.snippets.Peek(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantExpires.IsZero() {
+				assert.Equal(t, s.Expires.Sub(now).Round(time.Hour), 24*time.Hour)
+			} else {
+				assert.Equal(t, s.Expires.Equal(tt.wantExpires), true)
+			}
+		})
+	}
+}
+
+// Wraps a SnippetStore to delete each snippet just before it is changed, as
+// somebody else might between the handler looking it up and writing to it
+type deletingStore struct {
+	models.SnippetStore
+}
+
+func (d *deletingStore) Update(s *models.Snippet) error {
+	d.SnippetStore.Delete(s.ID)
+	return d.SnippetStore.Update(s)
+}
+
+func (d *deletingStore) Renew(id int, expires time.Time) error {
+	d.SnippetStore.Delete(id)
+	return d.SnippetStore.Renew(id, expires)
+}
+
+func TestSnippetDeletedWhileChanging(t *testing.T) {
+	tests := []struct {
+		name    string
+		urlPath string
+		form    url.Values // posted with a CSRF token, nil for the API
+		json    string
+	}{
+		{
+			name:    "Edit",
+			urlPath: "/snippet/edit/%d",
+			form:    url.Values{"title": {"O snail"}, "content": {"Climb Mount Fuji"}, "language": {plainText}, "visibility": {models.VisibilityPublic}},
+		},
+		{
+			name:    "Renew",
+			urlPath: "/snippet/renew/%d",
+			form:    url.Values{"expires": {"1"}, "expires_unit": {expiresDays}},
+		},
+		{
+			name:    "API update",
+			urlPath: "/api/v1/snippets/%d",
+			json:    `{"title": "O snail", "content": "Climb Mount Fuji"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			useMemoryStores(t, app)
+
+			id, err := app.snippets.Insert(&models.Snippet{Title: "Old pond", Content: "Frog jumps in", Language: plainText, Visibility: models.VisibilityPublic, AuthorID: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.snippets = &deletingStore{SnippetStore: app.snippets}
+
+			ts := newTestServer(t, app.routes())
+			urlPath := fmt.Sprintf(tt.urlPath, id)
+
+			var code int
+			if tt.form != nil {
+				tt.form.Set("csrf_token", ts.login(t, mocks.UserEmail, mocks.UserPassword))
+				code, _, _ = ts.postForm(t, urlPath, tt.form)
+			} else {
+				token, err := app.tokens.Insert(1, "test", models.ScopeWrite)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req, err := http.NewRequest(http.MethodPut, ts.URL+urlPath, strings.NewReader(tt.json))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+				rs, err := ts.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				code, _, _ = readResponse(t, rs)
+			}
+
+			assert.Equal(t, code, http.StatusNotFound)
+		})
+	}
+}
+
+func TestAPISnippetListHidesContent(t *testing.T) {
+	app := newTestApplication(t)
+	useMemoryStores(t, app)
+
+	protected := &models.Snippet{Title: "Protected pond", Content: "TOPSECRET-PW"}
+	err := protected.SetPassword("unlockPa$$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := &models.Snippet{Title: "Limited pond", Content: "TOPSECRET-BURN", MaxViews: 1}
+	plain := &models.Snippet{Title: "Plain pond", Content: "TOPSECRET-PLAIN"}
+
+	var limitedID int
+	for _, s := range []*models.Snippet{protected, limited, plain} {
+		s.Language, s.Visibility, s.AuthorID, s.Tags = plainText, models.VisibilityPublic, 1, []string{"pond"}
+		id, err := app.snippets.Insert(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == limited {
+			limitedID = id
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+
+	for _, urlPath := range []string{"/api/v1/snippets", "/api/v1/snippets?tag=pond", "/api/v1/search?q=pond"} {
+		t.Run(urlPath, func(t *testing.T) {
+			// Listing twice shows whether the first list used up a view
+			for i := 0; i < 2; i++ {
+				code, _, body := ts.get(t, urlPath)
+
+				assert.Equal(t, code, http.StatusOK)
+				assert.StringContains(t, body, "Plain pond")
+				assert.Equal(t, strings.Contains(body, "TOPSECRET"), false)
+			}
+		})
+	}
+
+	// The content is only handed out one snippet at a time, where the view
+	// limit applies
+	code, _, body := ts.get(t, fmt.Sprintf("/api/v1/snippets/%d", limitedID))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "TOPSECRET-BURN")
+
+	code, _, _ = ts.get(t, fmt.Sprintf("/api/v1/snippets/%d", limitedID))
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -2,13 +2,23 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"snippetbox.victorsmith.dev/internal/models"
+
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
@@ -51,6 +61,55 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// Largest request body the API accepts
+const maxJSONBytes = 1 << 20
+
+// Writes data as the JSON response body with the given status code
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// Decodes a JSON request body into dest. Unknown fields, trailing data and
+// bodies over maxJSONBytes are rejected.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dest any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dest)
+	if err != nil {
+		return err
+	}
+
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// The API counterpart of clientError: sends {"error": message}
+func (app *application) errorJSON(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]string{"error": message})
+}
+
+// The API counterpart of serverError
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	app.errorJSON(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
 // For consistency, we'll also implement a notFound helper. This is simply a
 // convenience wrapper around clientError which sends a 404 Not Found response to
 // the user.
@@ -84,10 +143,11 @@ func (app *application) render(w http.ResponseWriter, data *templateData, status
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear: time.Now().Year(),
-		Flash: app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken: nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
 
@@ -104,3 +164,352 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// Returns the id of the authenticated user (0 if there is none)
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, _ := r.Context().Value(authenticatedUserIDContextKey).(int)
+	return id
+}
+
+// Looks up the snippet named by the :id route param and checks that it belongs
+// to the logged in user. Responds with 404 for unknown snippets and for those
+// the user isn't allowed to see (so hidden snippets can't be told apart from
+// missing ones), and with 403 for public snippets owned by someone else. The
+// bool is false if a response was written.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Peek(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, snippet, false) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	if snippet.AuthorID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Reports whether the current user wrote snippet s
+func (app *application) isAuthor(r *http.Request, s *models.Snippet) bool {
+	return app.isAuthenticated(r) && s.AuthorID == app.authenticatedUserID(r)
+}
+
+// Reports whether the current user may see snippet s. Private snippets are
+// only visible to their author, unlisted ones also to anybody who came
+// through the share link (viaSlug).
+func (app *application) canView(r *http.Request, s *models.Snippet, viaSlug bool) bool {
+	if app.isAuthor(r, s) {
+		return true
+	}
+
+	switch s.Visibility {
+	case models.VisibilityPublic:
+		return true
+	case models.VisibilityUnlisted:
+		return viaSlug
+	default:
+		return false
+	}
+}
+
+// Looks up the snippet with id when it is opened by id rather than share link.
+// Responds with 404 if it doesn't exist or the current user isn't allowed to
+// see it (so hidden snippets can't be told apart from missing ones).
+// The bool is false if a response was written. This doesn't count as a view.
+func (app *application) visibleSnippet(w http.ResponseWriter, r *http.Request, id int) (*models.Snippet, bool) {
+	if id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Peek(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, snippet, false) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Like visibleSnippet, but a password protected snippet which hasn't been
+// unlocked yet sends the user to the view page to enter the password. Used by
+// pages which show the content of a snippet without counting a view, so
+// snippets with a view limit are only handed out to their author.
+func (app *application) unlockedSnippet(w http.ResponseWriter, r *http.Request, id int) (*models.Snippet, bool) {
+	snippet, ok := app.visibleSnippet(w, r, id)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.MaxViews > 0 && !app.isAuthor(r, snippet) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	if app.isLocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Counts a view of s unless the current user wrote it, and returns the
+// snippet as it was read. Responds with 404 if someone else has used up the
+// last view since s was looked up. The bool is false if a response was written.
+func (app *application) countView(w http.ResponseWriter, r *http.Request, s *models.Snippet) (*models.Snippet, bool) {
+	if app.isAuthor(r, s) {
+		return s, true
+	}
+
+	viewed, err := app.snippets.Get(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return viewed, true
+}
+
+// Looks up the snippet for the raw and download routes, by :slug if the route
+// has one and by :id otherwise. These show the content just like the view
+// page, so the same checks apply and a view is counted. A password protected
+// snippet which hasn't been unlocked yet sends the user to the view page.
+// The bool is false if a response was written.
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	var snippet *models.Snippet
+	viewURL := ""
+
+	if slug := params.ByName("slug"); slug != "" {
+		var err error
+		snippet, err = app.snippets.GetBySlug(slug)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return nil, false
+		}
+
+		if !app.canView(r, snippet, true) {
+			app.notFound(w)
+			return nil, false
+		}
+		viewURL = "/s/" + snippet.Slug
+	} else {
+		var ok bool
+		snippet, ok = app.visibleSnippet(w, r, readIntParam(r, "id"))
+		if !ok {
+			return nil, false
+		}
+		viewURL = fmt.Sprintf("/snippet/view/%d", snippet.ID)
+	}
+
+	if app.isLocked(r, snippet) {
+		http.Redirect(w, r, viewURL, http.StatusSeeOther)
+		return nil, false
+	}
+
+	return app.countView(w, r, snippet)
+}
+
+// Writes the content of s as a plain text response. The response isn't cached
+// anywhere since every request counts as a view.
+func (app *application) writeRaw(w http.ResponseWriter, s *models.Snippet) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, s.Content)
+}
+
+// Characters which can't appear in download file names
+var unsafeFilenameRegexp = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// Builds the file name a snippet is downloaded as: the title in lowercase with
+// runs of other characters turned into dashes (at most 64 characters), plus
+// an extension picked by language. Markdown snippets are saved as .md.
+func downloadName(s *models.Snippet) string {
+	name := unsafeFilenameRegexp.ReplaceAllString(strings.ToLower(s.Title), "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+
+	if s.Markdown {
+		return name + ".md"
+	}
+	return name + languageExt(s.Language)
+}
+
+// Session key under which the unlocking of a snippet is remembered
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlockedSnippet:%d", id)
+}
+
+// Reports whether the current user still has to enter a password to see s.
+// Authors never have to.
+func (app *application) isLocked(r *http.Request, s *models.Snippet) bool {
+	if len(s.PasswordHash) == 0 {
+		return false
+	}
+
+	if app.isAuthor(r, s) {
+		return false
+	}
+
+	// The hash is stored rather than a flag so that changing the password
+	// locks the snippet again
+	return app.sessionManager.GetString(r.Context(), unlockKey(s.ID)) != string(s.PasswordHash)
+}
+
+// Remembers in the session that the current user has unlocked s
+func (app *application) unlock(r *http.Request, s *models.Snippet) {
+	app.sessionManager.Put(r.Context(), unlockKey(s.ID), string(s.PasswordHash))
+}
+
+// Links rendered underneath a paginated list of snippets
+type pagination struct {
+	Number int    // 1-based page number, only used for display
+	Newer  string // URL of the previous (newer) page, empty on the first page
+	Older  string // URL of the next (older) page, empty on the last page
+}
+
+// Reads the ?before=, ?after= and ?page= query params. Invalid values are
+// treated as if they were missing. One more snippet than the page size is
+// requested so that paginate can tell whether there is another page.
+func (app *application) readPage(r *http.Request) (models.Page, int) {
+	qs := r.URL.Query()
+
+	page := models.Page{
+		Before: readInt(qs, "before"),
+		After:  readInt(qs, "after"),
+		Limit:  app.pageSize + 1,
+	}
+
+	return page, max(readInt(qs, "page"), 1)
+}
+
+// Trims the extra snippet fetched by readPage and works out the links to the
+// neighbouring pages.
+func (app *application) paginate(snippets []*models.Snippet, page models.Page, number int) ([]*models.Snippet, pagination) {
+	p := pagination{Number: number}
+
+	hasNewer, hasOlder := false, false
+	if page.After > 0 {
+		// Paging forwards: the extra snippet is the newest one
+		hasOlder = true
+		if len(snippets) > app.pageSize {
+			snippets = snippets[1:]
+			hasNewer = true
+		} else {
+			// Ran into the newest snippet, so this is really the first page
+			p.Number = 1
+		}
+	} else {
+		hasNewer = page.Before > 0
+		if len(snippets) > app.pageSize {
+			snippets = snippets[:app.pageSize]
+			hasOlder = true
+		}
+	}
+
+	if len(snippets) == 0 {
+		return snippets, p
+	}
+
+	if hasNewer {
+		p.Newer = pageURL("after", snippets[0].ID, max(p.Number-1, 1))
+	}
+	if hasOlder {
+		p.Older = pageURL("before", snippets[len(snippets)-1].ID, p.Number+1)
+	}
+
+	return snippets, p
+}
+
+// Builds a relative URL (query string only) for a page of snippets
+func pageURL(cursor string, id int, number int) string {
+	qs := url.Values{}
+	qs.Set(cursor, strconv.Itoa(id))
+	qs.Set("page", strconv.Itoa(number))
+	return "?" + qs.Encode()
+}
+
+// Builds the URL of a page of search results
+func searchURL(query string, number int) string {
+	qs := url.Values{}
+	qs.Set("q", query)
+	qs.Set("page", strconv.Itoa(number))
+	return "/snippet/search?" + qs.Encode()
+}
+
+// Returns the value of an integer route param, or 0 if it is missing, invalid or not positive
+func readIntParam(r *http.Request, name string) int {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	i, err := strconv.Atoi(params.ByName(name))
+	if err != nil || i < 1 {
+		return 0
+	}
+	return i
+}
+
+// Returns the integer value of a query param, or 0 if it is missing or invalid
+func readInt(qs url.Values, key string) int {
+	i, err := strconv.Atoi(qs.Get(key))
+	if err != nil || i < 0 {
+		return 0
+	}
+	return i
+}
+
+// Splits a comma separated list of tags, normalising each one to lowercase
+// and dropping blanks and duplicates. The result is sorted.
+func parseTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+func TestPaginate(t *testing.T) {
+	app := &application{pageSize: 2}
+
+	// Builds a list of snippets with the given ids (newest first)
+	list := func(ids ...int) []*models.Snippet {
+		snippets := []*models.Snippet{}
+		for _, id := range ids {
+			snippets = append(snippets, &models.Snippet{ID: id})
+		}
+		return snippets
+	}
+
+	tests := []struct {
+		name     string
+		snippets []*models.Snippet
+		page     models.Page
+		number   int
+		wantLen  int
+		want     pagination
+	}{
+		{name: "Only page", snippets: list(2, 1), page: models.Page{}, number: 1, wantLen: 2, want: pagination{Number: 1}},
+		{name: "First page", snippets: list(9, 8, 7), page: models.Page{}, number: 1, wantLen: 2, want: pagination{Number: 1, Older: "?before=8&page=2"}},
+		{name: "Middle page", snippets: list(7, 6, 5), page: models.Page{Before: 8}, number: 2, wantLen: 2, want: pagination{Number: 2, Newer: "?after=7&page=1", Older: "?before=6&page=3"}},
+		{name: "Last page", snippets: list(5), page: models.Page{Before: 6}, number: 3, wantLen: 1, want: pagination{Number: 3, Newer: "?after=5&page=2"}},
+		{name: "Back a page", snippets: list(8, 7, 6), page: models.Page{After: 5}, number: 2, wantLen: 2, want: pagination{Number: 2, Newer: "?after=7&page=1", Older: "?before=6&page=3"}},
+		{name: "Back to the start", snippets: list(9, 8), page: models.Page{After: 7}, number: 2, wantLen: 2, want: pagination{Number: 1, Older: "?before=8&page=2"}},
+		{name: "Empty", snippets: list(), page: models.Page{Before: 1}, number: 4, wantLen: 0, want: pagination{Number: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, p := app.paginate(tt.snippets, tt.page, tt.number)
+
+			assert.Equal(t, len(snippets), tt.wantLen)
+			assert.Equal(t, p, tt.want)
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Empty", input: "", want: ""},
+		{name: "Blanks", input: " , ,", want: ""},
+		{name: "Normalised", input: "SQL, k8s ,oncall", want: "k8s,oncall,sql"},
+		{name: "Duplicates", input: "sql,SQL, sql", want: "sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, strings.Join(parseTags(tt.input), ","), tt.want)
+		})
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet models.Snippet
+		want    string
+	}{
+		{name: "Language extension", snippet: models.Snippet{Title: "Deploy Script!", Language: "bash"}, want: "deploy-script.sh"},
+		{name: "Markdown", snippet: models.Snippet{Title: "README", Language: plainText, Markdown: true}, want: "readme.md"},
+		{name: "Unknown language", snippet: models.Snippet{Title: "notes", Language: "cobol"}, want: "notes.txt"},
+		{name: "Nothing usable in the title", snippet: models.Snippet{ID: 7, Title: "日本語", Language: "yaml"}, want: "snippet-7.yaml"},
+		{name: "Long title", snippet: models.Snippet{Title: strings.Repeat("ab ", 40), Language: "go"}, want: strings.Repeat("ab-", 21) + "a.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, downloadName(&tt.snippet), tt.want)
+		})
+	}
+}
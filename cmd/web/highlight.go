@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// A language offered by the language picker on the create and edit pages.
+// Name is the chroma lexer name stored with the snippet, Ext the file
+// extension used for downloads.
+type language struct {
+	Name  string
+	Label string
+	Ext   string
+}
+
+const plainText = "plaintext"
+
+var languages = []language{
+	{Name: plainText, Label: "Plain text", Ext: ".txt"},
+	{Name: "bash", Label: "Bash", Ext: ".sh"},
+	{Name: "c", Label: "C", Ext: ".c"},
+	{Name: "cpp", Label: "C++", Ext: ".cpp"},
+	{Name: "css", Label: "CSS", Ext: ".css"},
+	{Name: "diff", Label: "Diff", Ext: ".diff"},
+	{Name: "docker", Label: "Dockerfile", Ext: ".dockerfile"},
+	{Name: "go", Label: "Go", Ext: ".go"},
+	{Name: "html", Label: "HTML", Ext: ".html"},
+	{Name: "ini", Label: "INI", Ext: ".ini"},
+	{Name: "java", Label: "Java", Ext: ".java"},
+	{Name: "javascript", Label: "JavaScript", Ext: ".js"},
+	{Name: "json", Label: "JSON", Ext: ".json"},
+	{Name: "php", Label: "PHP", Ext: ".php"},
+	{Name: "powershell", Label: "PowerShell", Ext: ".ps1"},
+	{Name: "python", Label: "Python", Ext: ".py"},
+	{Name: "ruby", Label: "Ruby", Ext: ".rb"},
+	{Name: "rust", Label: "Rust", Ext: ".rs"},
+	{Name: "sql", Label: "SQL", Ext: ".sql"},
+	{Name: "terraform", Label: "Terraform", Ext: ".tf"},
+	{Name: "toml", Label: "TOML", Ext: ".toml"},
+	{Name: "typescript", Label: "TypeScript", Ext: ".ts"},
+	{Name: "yaml", Label: "YAML", Ext: ".yaml"},
+}
+
+// Returns the file extension for snippets in the named language, ".txt" if
+// the language isn't known
+func languageExt(name string) string {
+	for _, l := range languages {
+		if l.Name == name {
+			return l.Ext
+		}
+	}
+	return ".txt"
+}
+
+// Returns the names of the languages in the picker, for validation
+func languageNames() []string {
+	names := make([]string, len(languages))
+	for i, l := range languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Emits CSS class names instead of inline styles so the markup is allowed by
+// our Content-Security-Policy. The classes are styled at the bottom of
+// ui/static/css/main.css (generated from chroma's "github" style).
+var codeFormatter = html.New(html.WithClasses(true))
+
+// Renders content as syntax highlighted HTML for the given language. Plain
+// text, unknown languages and tokenizer errors all fall back to escaped text.
+func highlightCode(content, lang string) template.HTML {
+	plain := template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+
+	if lang == plainText {
+		return plain
+	}
+
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return plain
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return plain
+	}
+
+	buf := new(bytes.Buffer)
+	err = codeFormatter.Format(buf, styles.Get("github"), iterator)
+	if err != nil {
+		return plain
+	}
+
+	return template.HTML(buf.String())
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
@@ -22,29 +25,24 @@ import (
 type application struct {
 	infoLog        *log.Logger
 	errorLog       *log.Logger
-	snippets       *models.SnippetModel
-	users          *models.UserModel
+	snippets       models.SnippetStore
+	users          models.UserStore
+	tokens         models.TokenStore
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
-}
-
-// for a given DSN.
-
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, err
-	}
-	if err = db.Ping(); err != nil {
-		return nil, err
-	}
-	return db, nil
+	pageSize       int           // number of snippets per page in listings
+	maxExpiry      time.Duration // longest lifetime a snippet can be given, unless it never expires
 }
 
 func main() {
 	addr := flag.String("addr", ":4000", "http network address")
-	dsn := flag.String("dsn", "root:snippet@/snippetbox?parseTime=true", "Database Connection String")
+	driver := flag.String("db-driver", driverMySQL, "Database to use: mysql, postgres, sqlite or memory (nothing is kept, for demos)")
+	dsn := flag.String("dsn", "", "Database Connection String (a file path for sqlite, defaults depend on db-driver)")
+	pageSize := flag.Int("page-size", 10, "Number of snippets listed per page")
+	maxExpiry := flag.Duration("max-expiry", 366*24*time.Hour, "Longest lifetime a snippet can be given (snippets which never expire aside)")
+	purgeInterval := flag.Duration("purge-interval", time.Hour, "How often expired snippets are deleted (0 disables purging)")
+	purgeBatch := flag.Int("purge-batch", 500, "Number of expired snippets deleted per statement")
 	// Must call parse, or default value will be used
 	flag.Parse()
 
@@ -52,13 +50,43 @@ func main() {
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	// openDB is a helper function which connects our application to a mysql db
-	db, err := openDB(*dsn)
+	if *pageSize < 1 {
+		errorLog.Fatal("page-size must be at least 1")
+	}
+	if *maxExpiry < time.Hour {
+		errorLog.Fatal("max-expiry must be at least an hour")
+	}
+	if *purgeInterval < 0 || *purgeBatch < 1 {
+		errorLog.Fatal("purge-interval can't be negative and purge-batch must be at least 1")
+	}
+
+	// openBackend connects our application to the database and sets up the models for it
+	b, err := openBackend(*driver, *dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	// Closes db connection pool before main exits
-	defer db.Close()
+	defer b.Close()
+
+	// "web migrate up|down|status" manages the schema instead of serving
+	if flag.Arg(0) == "migrate" {
+		if b.db == nil {
+			errorLog.Fatalf("the %s driver has no schema to migrate", *driver)
+		}
+		err = runMigrate(b.db, *driver, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	// Refuse to start against a schema the code doesn't match
+	if b.db != nil {
+		err = checkSchema(b.db, *driver)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
 
 	// initialize template cache
 	cache, err := newTemplateCache()
@@ -68,7 +96,7 @@ func main() {
 
 	// Initialize session manager (w/ 12 hour time limit)
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
+	sessionManager.Store = b.sessions
 	sessionManager.Lifetime = 12 * time.Hour
 	// Cookie will only be sent via browser when https connection is being used (http is ignored)
 	sessionManager.Cookie.Secure = true
@@ -79,11 +107,14 @@ func main() {
 	app := &application{
 		infoLog:        infoLog,
 		errorLog:       errorLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		snippets:       b.snippets,
+		users:          b.users,
+		tokens:         b.tokens,
 		templateCache:  cache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		pageSize:       *pageSize,
+		maxExpiry:      *maxExpiry,
 	}
 
 	// these curve implementatiosn are written in assembly => very fast
@@ -99,7 +130,40 @@ func main() {
 		TLSConfig: tlsConfig,
 	}
 
+	// Cancelled on Ctrl-C or SIGTERM, which starts a clean shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	if *purgeInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.purgeExpired(ctx, *purgeInterval, *purgeBatch)
+		}()
+	}
+
+	// Let requests in flight finish (for up to 10 seconds) once we are told to stop
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		infoLog.Print("Shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errorLog.Print(err)
+		}
+	}()
+
 	infoLog.Printf("Starting server on %s", *addr)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	// ListenAndServeTLS returns as soon as Shutdown is called, so wait for
+	// Shutdown and the purger to finish before the deferred db.Close runs
+	wg.Wait()
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// GitHub flavoured Markdown (tables, strikethrough, autolinks and task lists).
+// Raw HTML in the source is left out by goldmark's default (unsafe = false)
+// renderer, but we still run the output through the sanitizer below.
+var markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// Allows the markup people expect from user generated content and strips
+// everything else (scripts, styles, event handlers, javascript: URLs...).
+// Links get rel="nofollow" and code blocks keep their language-xxx class.
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile("^language-[a-zA-Z0-9+#-]+$")).OnElements("code")
+	return p
+}()
+
+// Renders Markdown source as sanitized HTML. If rendering fails the escaped
+// source is shown instead.
+func markdown(content string) template.HTML {
+	buf := new(bytes.Buffer)
+	err := markdownRenderer.Convert([]byte(content), buf)
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes()))
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"snippetbox.victorsmith.dev/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -103,8 +107,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// create a new copy of the request (with an isAuthenticatedContextKey 
 		// value of true in the request context) and assign it to r.
 		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+			r = withAuthenticatedUser(r, id)
 		}
 
 		fmt.Println("gets here 1")
@@ -113,3 +116,69 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Returns a copy of r marking the user with the given id as authenticated
+func withAuthenticatedUser(r *http.Request, id int) *http.Request {
+	ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+	ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// Authenticates API requests from the personal API token sent with each
+// request (Authorization: Bearer). Requests without a token carry on
+// anonymously, unknown or expired tokens are turned away.
+func (app *application) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses depend on who is asking
+		w.Header().Add("Vary", "Authorization")
+
+		plaintext, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := app.tokens.Authenticate(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.errorJSON(w, http.StatusUnauthorized, "invalid credentials")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		r = withAuthenticatedUser(r, token.UserID)
+		r = r.WithContext(context.WithValue(r.Context(), apiScopeContextKey, token.Scope))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The API counterpart of requireAuthentication: answers 401 instead of
+// redirecting to the login page.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+			app.errorJSON(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Turns away API requests authenticated with a read-only token. Goes after
+// requireAPIAuthentication.
+func (app *application) requireWriteScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, _ := r.Context().Value(apiScopeContextKey).(string)
+		if scope != models.ScopeWrite {
+			app.errorJSON(w, http.StatusForbidden, "this token is read-only")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/models"
+	"snippetbox.victorsmith.dev/internal/models/mocks"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -61,3 +65,131 @@ func TestSecureHeaders(t *testing.T) {
 	bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestAuthenticateAPI(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Answers with the id of the user the request was authenticated as
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(app.authenticatedUserID(r))))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		basicAuth     bool
+		wantStatus    int
+		wantBody      string
+	}{
+		{name: "Anonymous", wantStatus: http.StatusOK, wantBody: "0"},
+		{name: "Valid token", authorization: "Bearer " + mocks.Token, wantStatus: http.StatusOK, wantBody: "1"},
+		{name: "Unknown token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		// The account password isn't accepted in place of a token
+		{name: "Basic auth", basicAuth: true, wantStatus: http.StatusOK, wantBody: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/api/v1/snippets", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.basicAuth {
+				r.SetBasicAuth(mocks.UserEmail, mocks.UserPassword)
+			}
+
+			app.authenticateAPI(next).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			assert.Equal(t, rs.StatusCode, tt.wantStatus)
+			if tt.wantStatus == http.StatusOK {
+				body, err := io.ReadAll(rs.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, string(body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRequireAPIAuthentication(t *testing.T) {
+	app := &application{}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name       string
+		userID     int
+		wantStatus int
+	}{
+		{name: "Anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "Authenticated", userID: 1, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodPost, "/api/v1/snippets", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.userID > 0 {
+				r = withAuthenticatedUser(r, tt.userID)
+			}
+
+			app.requireAPIAuthentication(next).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			assert.Equal(t, rs.StatusCode, tt.wantStatus)
+
+			// Anonymous callers are told how to authenticate, in JSON
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, rs.Header.Get("Content-Type"), "application/json")
+				assert.Equal(t, rs.Header.Get("WWW-Authenticate"), `Bearer realm="snippetbox"`)
+			}
+		})
+	}
+}
+
+func TestRequireWriteScope(t *testing.T) {
+	app := &application{}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name       string
+		scope      string
+		wantStatus int
+	}{
+		{name: "Read", scope: models.ScopeRead, wantStatus: http.StatusForbidden},
+		{name: "Write", scope: models.ScopeWrite, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodPost, "/api/v1/snippets", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = withAuthenticatedUser(r, 1)
+			r = r.WithContext(context.WithValue(r.Context(), apiScopeContextKey, tt.scope))
+
+			app.requireWriteScope(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Result().StatusCode, tt.wantStatus)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"snippetbox.victorsmith.dev/internal/migrate"
+	"snippetbox.victorsmith.dev/migrations"
+)
+
+// How each database is asked whether the schema_migrations table exists
+var countMigrationsTable = map[string]string{
+	driverMySQL:    `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`,
+	driverPostgres: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`,
+	driverSQLite:   `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+}
+
+// Returns a migrator with the embedded migrations for driver
+func newMigrator(db *sql.DB, driver string) (*migrate.Migrator, error) {
+	m, err := migrate.Load(migrations.Files, driver)
+	if err != nil {
+		return nil, err
+	}
+	return &migrate.Migrator{DB: db, Migrations: m, CountTable: countMigrationsTable[driver]}, nil
+}
+
+// Runs the migrate subcommand: "migrate up" applies every pending migration,
+// "migrate down" reverts the latest one and "migrate status" lists them.
+func runMigrate(db *sql.DB, driver string, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: web [flags] migrate up|down|status")
+	}
+
+	m, err := newMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "Database is up to date")
+		}
+	case "down":
+		reverted, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Fprintln(out, "No migrations to revert")
+		} else {
+			fmt.Fprintf(out, "Reverted %04d_%s\n", reverted.Version, reverted.Name)
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.Applied.IsZero() {
+				applied = s.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+	}
+
+	return nil
+}
+
+// Returns an error if the schema of db isn't exactly what the embedded
+// migrations describe, so we don't serve against a database missing columns.
+// Only reads: nothing is written to the database, not even the bookkeeping
+// table.
+func checkSchema(db *sql.DB, driver string) error {
+	m, err := newMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+
+	err = m.Check()
+	if errors.Is(err, migrate.ErrOutOfDate) {
+		return fmt.Errorf("%w (run \"migrate up\" or \"migrate status\")", err)
+	}
+	return err
+}
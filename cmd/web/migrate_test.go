@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/migrate"
+)
+
+func TestCheckSchema(t *testing.T) {
+	db, err := openDB(driverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	countTables := func() int {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// A new database is out of date, and checking leaves it empty
+	err = checkSchema(db, driverSQLite)
+	assert.Equal(t, errors.Is(err, migrate.ErrOutOfDate), true)
+	assert.Equal(t, countTables(), 0)
+
+	err = runMigrate(db, driverSQLite, []string{"status"}, io.Discard)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, countTables(), 0)
+
+	err = runMigrate(db, driverSQLite, []string{"up"}, io.Discard)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, checkSchema(db, driverSQLite), nil)
+
+	err = runMigrate(db, driverSQLite, []string{"down"}, io.Discard)
+	assert.Equal(t, err, nil)
+	err = checkSchema(db, driverSQLite)
+	assert.Equal(t, errors.Is(err, migrate.ErrOutOfDate), true)
+}
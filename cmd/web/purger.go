@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Deletes expired snippets every interval, batchSize at a time, until ctx is
+// cancelled. Expired snippets are already hidden everywhere, this only keeps
+// them from piling up in the database.
+func (app *application) purgeExpired(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeOnce(ctx, batchSize)
+		}
+	}
+}
+
+// Deletes batches of expired snippets until there are none left (or ctx is
+// cancelled) and logs how many were removed.
+func (app *application) purgeOnce(ctx context.Context, batchSize int) {
+	total := 0
+	defer func() {
+		if total > 0 {
+			app.infoLog.Printf("Purged %d expired snippets", total)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(batchSize)
+		if err != nil {
+			// Try again on the next tick
+			app.errorLog.Printf("purging expired snippets: %v", err)
+			return
+		}
+
+		total += n
+		if n < batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Wraps a SnippetStore to record what each DeleteExpired call removed, and to
+// fail the call numbered failAt (counting from 1, 0 never fails)
+type purgeRecorder struct {
+	models.SnippetStore
+	deleted []int
+	failAt  int
+}
+
+func (p *purgeRecorder) DeleteExpired(limit int) (int, error) {
+	if len(p.deleted)+1 == p.failAt {
+		p.deleted = append(p.deleted, -1)
+		return 0, errors.New("database went away")
+	}
+
+	n, err := p.SnippetStore.DeleteExpired(limit)
+	p.deleted = append(p.deleted, n)
+	return n, err
+}
+
+// Returns an application on the memory stores holding n expired snippets,
+// with its store wrapped in a purgeRecorder and its logs kept in buffers
+func newPurgeApplication(t *testing.T, n int) (*application, *purgeRecorder, *bytes.Buffer, *bytes.Buffer) {
+	app := newTestApplication(t)
+	db := useMemoryStores(t, app)
+
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		_, err := app.snippets.Insert(&models.Snippet{Title: "Old pond", Content: "Frog jumps in", Visibility: models.VisibilityPublic, AuthorID: 1, Expires: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	recorder := &purgeRecorder{SnippetStore: app.snippets}
+	app.snippets = recorder
+
+	var infoLog, errorLog bytes.Buffer
+	app.infoLog = log.New(&infoLog, "", 0)
+	app.errorLog = log.New(&errorLog, "", 0)
+
+	return app, recorder, &infoLog, &errorLog
+}
+
+func TestPurgeOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		expired     int
+		failAt      int
+		cancelled   bool
+		wantDeleted string // what each DeleteExpired call removed, -1 for a failed call
+		wantInfo    string
+		wantError   string
+	}{
+		{name: "Last batch short", expired: 5, wantDeleted: "[2 2 1]", wantInfo: "Purged 5 expired snippets\n"},
+		{name: "Last batch empty", expired: 4, wantDeleted: "[2 2 0]", wantInfo: "Purged 4 expired snippets\n"},
+		{name: "Nothing expired", expired: 0, wantDeleted: "[0]"},
+		{name: "Cancelled", expired: 5, cancelled: true, wantDeleted: "[]"},
+		{name: "Error", expired: 5, failAt: 2, wantDeleted: "[2 -1]", wantInfo: "Purged 2 expired snippets\n", wantError: "purging expired snippets: database went away\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder, infoLog, errorLog := newPurgeApplication(t, tt.expired)
+			recorder.failAt = tt.failAt
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelled {
+				cancel()
+			}
+			defer cancel()
+
+			app.purgeOnce(ctx, 2)
+
+			assert.Equal(t, fmt.Sprint(recorder.deleted), tt.wantDeleted)
+			assert.Equal(t, infoLog.String(), tt.wantInfo)
+			assert.Equal(t, errorLog.String(), tt.wantError)
+		})
+	}
+}
+
+func TestPurgeExpiredStops(t *testing.T) {
+	app, _, _, _ := newPurgeApplication(t, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.purgeExpired(ctx, time.Millisecond, 2)
+		close(done)
+	}()
+
+	// Let it tick a few times before stopping it
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purgeExpired didn't return after its context was cancelled")
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 	"io/fs"
 
+	"snippetbox.victorsmith.dev/internal/diff"
 	"snippetbox.victorsmith.dev/internal/models"
 	"snippetbox.victorsmith.dev/ui"
 )
@@ -19,21 +22,115 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// Builds a case-insensitive regexp matching any of the words in query.
+// Returns nil if there are no words.
+func queryRegexp(query string) *regexp.Regexp {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	for i := range words {
+		words[i] = regexp.QuoteMeta(words[i])
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(words, "|"))
+}
+
+// Escapes text and wraps every occurrence of a word from query in <mark> tags.
+func highlight(text, query string) template.HTML {
+	rx := queryRegexp(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// Number of characters of content shown for each search result
+const excerptLength = 160
+
+// Returns roughly excerptLength characters of text around the first word from
+// query, so that search results show why they matched.
+func excerpt(text, query string) string {
+	runes := []rune(text)
+	if len(runes) <= excerptLength {
+		return text
+	}
+
+	start := 0
+	if rx := queryRegexp(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			// Convert the byte offset to a rune offset and leave some context
+			start = max(len([]rune(text[:loc[0]]))-excerptLength/4, 0)
+		}
+	}
+	end := min(start+excerptLength, len(runes))
+	start = max(end-excerptLength, 0)
+
+	out := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
 // { string: function } map => used to fetch functions in template
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"highlight":     highlight,
+	"excerpt":       excerpt,
+	"highlightCode": highlightCode,
+	"markdown":      markdown,
+	"languages":     func() []language { return languages },
+	"expiryUnits":   func() []string { return expiryUnits },
 }
 
 // Make a holding structure for incoming data
 // Can expand if we wish to add additional data later on
 type templateData struct {
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	CurrentYear     int
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Revision            *models.Revision
+	Revisions           []*models.Revision
+	Diff                *diffView
+	Pagination          pagination
+	Query               string
+	Tag                 string
+	Tokens              []*models.Token
+	NewToken            string // plaintext of a token which was just created
+	CurrentYear         int
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int // 0 if IsAuthenticated is false
+	CSRFToken           string
+}
+
+// One of the two things being compared on the diff page
+type diffSide struct {
+	Title string
+	URL   string
+}
+
+type diffView struct {
+	From  diffSide
+	To    diffSide
+	Hunks []diff.Hunk
 }
 
 // filename: []ts
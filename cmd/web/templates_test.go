@@ -1,10 +1,14 @@
 package main
 
 import (
+	"html/template"
+	"strings"
 	"testing"
 	"time"
 
 	"snippetbox.victorsmith.dev/internal/assert"
+
+	"github.com/alecthomas/chroma/v2/lexers"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -30,4 +34,106 @@ func TestHumanDate(t *testing.T) {
 	
 			assert.Equal(t, hd, tt.want)
 	}	
-}
\ No newline at end of file
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  template.HTML
+	}{
+		{name: "No query", text: "a <b>", query: " ", want: "a &lt;b&gt;"},
+		{name: "Single word", text: "SELECT * FROM users", query: "from", want: "SELECT * <mark>FROM</mark> users"},
+		{name: "Several words", text: "kubectl get pods", query: "get pods", want: "kubectl <mark>get</mark> <mark>pods</mark>"},
+		{name: "Escapes matches", text: "if a<b {", query: "<b", want: "if a<mark>&lt;b</mark> {"},
+		{name: "Regexp characters", text: "a.b axb", query: "a.b", want: "<mark>a.b</mark> axb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, highlight(tt.text, tt.query), tt.want)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("x", 200) + " needle " + strings.Repeat("y", 200)
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{name: "Short text", text: "short text", query: "text", want: "short text"},
+		{name: "No match", text: long, query: "hay", want: strings.Repeat("x", excerptLength) + "…"},
+		{name: "Around match", text: long, query: "needle", want: "…" + strings.Repeat("x", 39) + " needle " + strings.Repeat("y", 113) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, excerpt(tt.text, tt.query), tt.want)
+		})
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string // substring of the output
+	}{
+		{name: "Plain text", content: "<b>", language: plainText, want: "<pre><code>&lt;b&gt;</code></pre>"},
+		{name: "Unknown language", content: "<b>", language: "klingon", want: "<pre><code>&lt;b&gt;</code></pre>"},
+		{name: "Go keyword", content: "package main", language: "go", want: `<span class="kn">package</span>`},
+		{name: "Escapes strings", content: `x := "<script>"`, language: "go", want: "&lt;script&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := string(highlightCode(tt.content, tt.language))
+
+			assert.Equal(t, strings.Contains(out, tt.want), true)
+			// Styles must come from main.css, inline ones are blocked by the CSP
+			assert.Equal(t, strings.Contains(out, "style="), false)
+		})
+	}
+}
+
+func TestLanguagesHaveLexers(t *testing.T) {
+	// Every language in the picker except plain text should be highlighted
+	for _, l := range languages[1:] {
+		assert.Equal(t, lexers.Get(l.Name) != nil, true)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string // substring of the output
+		notWant string
+	}{
+		{name: "Prose", content: "Restart **nginx**", want: "<p>Restart <strong>nginx</strong></p>"},
+		{name: "Code fence", content: "```bash\nls -la\n```", want: `<pre><code class="language-bash">ls -la`},
+		{name: "Raw HTML", content: "<script>alert(1)</script>", notWant: "<script>"},
+		{name: "Javascript link", content: "[click](javascript:alert(1))", notWant: "javascript:"},
+		{name: "Event handler", content: `<img src="x" onerror="alert(1)">`, notWant: "onerror"},
+		{name: "Links", content: "[docs](https://go.dev)", want: `<a href="https://go.dev" rel="nofollow">docs</a>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := string(markdown(tt.content))
+
+			if tt.want != "" {
+				assert.Equal(t, strings.Contains(out, tt.want), true)
+			}
+			if tt.notWant != "" {
+				assert.Equal(t, strings.Contains(out, tt.notWant), false)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models/memory"
+	"snippetbox.victorsmith.dev/internal/models/mocks"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/go-playground/form/v4"
+)
+
+// Returns an application set up like the one main builds, but backed by the
+// mock stores and with its logs thrown away
+func newTestApplication(t *testing.T) *application {
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same settings as main, so the session cookie is only sent over HTTPS
+	// here too
+	sessionManager := scs.New()
+	sessionManager.Store = memstore.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	return &application{
+		infoLog:        log.New(io.Discard, "", 0),
+		errorLog:       log.New(io.Discard, "", 0),
+		snippets:       &mocks.SnippetModel{},
+		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+		pageSize:       10,
+		maxExpiry:      366 * 24 * time.Hour,
+	}
+}
+
+// Replaces the mock stores of app with in-memory ones, for tests which need
+// what they write to stick. The stores start out with the mock user (id 1,
+// who logs in with mocks.UserEmail and mocks.UserPassword) and nothing else.
+func useMemoryStores(t *testing.T, app *application) *memory.DB {
+	db := memory.New()
+	app.snippets = &memory.SnippetModel{DB: db}
+	app.users = &memory.UserModel{DB: db}
+	app.tokens = &memory.TokenModel{DB: db}
+
+	err := app.users.Insert("Alice", mocks.UserEmail, mocks.UserPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// A TLS test server with a client that keeps cookies between requests, like
+// a browser would
+type testServer struct {
+	*httptest.Server
+}
+
+// Starts a test server for h, which is closed when the test ends
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	// Return redirects as they are instead of following them, so tests can
+	// check where they lead
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// Makes a GET request to urlPath and returns the status code, headers and
+// body of the response
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return readResponse(t, rs)
+}
+
+// Posts form to urlPath and returns the status code, headers and body of the
+// response
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// nosurf rejects HTTPS posts without a same origin Referer, as a browser
+	// posting one of our forms would send
+	req.Header.Set("Referer", ts.URL+urlPath)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return readResponse(t, rs)
+}
+
+func readResponse(t *testing.T, rs *http.Response) (int, http.Header, string) {
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
+
+// Returns the CSRF token of the first form in a rendered page. html/template
+// escapes the token (+ becomes &#43;), so it is unescaped before being posted
+// back.
+func extractCSRFToken(t *testing.T, body string) string {
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
+
+// Logs in with the given credentials and returns a CSRF token valid for the
+// session
+func (ts *testServer) login(t *testing.T, email, password string) string {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in: got status %d", code)
+	}
+
+	// The token is tied to the CSRF cookie, which logging in leaves alone
+	_, _, body = ts.get(t, "/snippet/create")
+	return extractCSRFToken(t, body)
+}
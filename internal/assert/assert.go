@@ -1,6 +1,7 @@
 package assert
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -12,3 +13,12 @@ func Equal[T comparable](t *testing.T, actual, expeceted T) {
 		t.Errorf("Expected: %v => Actual: %v", expeceted, actual)	
 	}
 }
+
+// Fails the test unless actual contains expectedSubstring
+func StringContains(t *testing.T, actual, expectedSubstring string) {
+	t.Helper()
+
+	if !strings.Contains(actual, expectedSubstring) {
+		t.Errorf("Expected to contain: %q => Actual: %q", expectedSubstring, actual)
+	}
+}
@@ -0,0 +1,231 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Used as the CSS class of a line on the diff page
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// One line of a diff. OldLine and NewLine are the 1-based line numbers in the
+// old and new text (0 for a line which doesn't exist on that side).
+type Line struct {
+	Op      Op
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// A run of changed lines surrounded by some unchanged context, like the
+// "@@ -1,4 +1,5 @@" sections of a unified diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Returns the unified diff header of the hunk, e.g. "@@ -1,4 +1,5 @@"
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Above this many cells in the LCS table (roughly 2000 x 2000 lines) we give
+// up looking for common lines and report every line as replaced, so that a
+// pair of huge snippets can't eat all our memory.
+const maxCells = 4_000_000
+
+// Compares two texts line by line and groups the changes into hunks with up to
+// context unchanged lines either side. Returns nil if the texts are identical.
+func Compute(a, b string, context int) []Hunk {
+	return hunks(diffLines(splitLines(a), splitLines(b)), context)
+}
+
+// Splits text into lines, ignoring a trailing newline and treating \r\n like \n
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// Works out the shortest edit turning a into b using the longest common
+// subsequence of lines.
+func diffLines(a, b []string) []Line {
+	lines := []Line{}
+	oldLine, newLine := 1, 1
+
+	// Lines shared at the start and end are common in practice and don't
+	// need to go through the (quadratic) LCS table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	emit := func(op Op, text string) {
+		l := Line{Op: op, Text: text}
+		if op != Insert {
+			l.OldLine = oldLine
+			oldLine++
+		}
+		if op != Delete {
+			l.NewLine = newLine
+			newLine++
+		}
+		lines = append(lines, l)
+	}
+
+	for _, text := range a[:prefix] {
+		emit(Equal, text)
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+
+	if n*m > maxCells {
+		for _, text := range midA {
+			emit(Delete, text)
+		}
+		for _, text := range midB {
+			emit(Insert, text)
+		}
+	} else {
+		// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		// Walk the table, preferring deletions so they come before the
+		// insertions which replace them
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				emit(Equal, midA[i])
+				i++
+				j++
+			case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+				emit(Delete, midA[i])
+				i++
+			default:
+				emit(Insert, midB[j])
+				j++
+			}
+		}
+	}
+
+	for _, text := range a[len(a)-suffix:] {
+		emit(Equal, text)
+	}
+
+	return lines
+}
+
+// Groups changed lines into hunks, keeping up to context unchanged lines
+// around each change and merging hunks whose context would overlap.
+func hunks(lines []Line, context int) []Hunk {
+	var result []Hunk
+
+	i := 0
+	for i < len(lines) {
+		// Find the next change
+		for i < len(lines) && lines[i].Op == Equal {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := max(i-context, 0)
+
+		// Extend the hunk until we've seen more than 2*context unchanged
+		// lines in a row (or run out of lines)
+		end := i
+		equalRun := 0
+		for end < len(lines) && equalRun <= 2*context {
+			if lines[end].Op == Equal {
+				equalRun++
+			} else {
+				equalRun = 0
+			}
+			end++
+		}
+		// Only keep context lines after the last change
+		end -= max(equalRun-context, 0)
+
+		result = append(result, newHunk(lines[:start], lines[start:end]))
+		i = end
+	}
+
+	return result
+}
+
+// Builds the hunk made up of lines, where before holds all the lines preceding it
+func newHunk(before, lines []Line) Hunk {
+	oldBefore, newBefore := 0, 0
+	for _, l := range before {
+		if l.Op != Insert {
+			oldBefore++
+		}
+		if l.Op != Delete {
+			newBefore++
+		}
+	}
+
+	h := Hunk{Lines: lines}
+	for _, l := range lines {
+		if l.Op != Insert {
+			h.OldLines++
+		}
+		if l.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	// Like diff(1), a side with no lines in the hunk "starts" at the line
+	// before it
+	h.OldStart, h.NewStart = oldBefore, newBefore
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+
+	return h
+}
@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+)
+
+// Renders hunks in unified diff format so expectations are easy to read
+func unified(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			switch l.Op {
+			case Equal:
+				b.WriteString(" ")
+			case Insert:
+				b.WriteString("+")
+			case Delete:
+				b.WriteString("-")
+			}
+			b.WriteString(l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		context int
+		want    string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo\n",
+			b:    "one\r\ntwo",
+			want: "",
+		},
+		{
+			name: "Both empty",
+			want: "",
+		},
+		{
+			name: "From nothing",
+			a:    "",
+			b:    "one\ntwo",
+			want: "@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:    "Changed line",
+			a:       "a\nb\nc\nd\ne",
+			b:       "a\nb\nC\nd\ne",
+			context: 1,
+			want:    "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+		},
+		{
+			name:    "Pure insertion without context",
+			a:       "a\nb\nc",
+			b:       "a\nb\nx\nc",
+			context: 0,
+			want:    "@@ -2,0 +3,1 @@\n+x\n",
+		},
+		{
+			name:    "Separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8",
+			b:       "one\n2\n3\n4\n5\n6\n7\neight",
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+eight\n",
+		},
+		{
+			name:    "Merged hunks",
+			a:       "1\n2\n3\n4",
+			b:       "one\n2\n3\nfour",
+			context: 1,
+			want:    "@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n-4\n+four\n",
+		},
+		{
+			name:    "Moved line",
+			a:       "a\nb\nc",
+			b:       "b\nc\na",
+			context: 3,
+			want:    "@@ -1,3 +1,3 @@\n-a\n b\n c\n+a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, unified(Compute(tt.a, tt.b, tt.context)), tt.want)
+		})
+	}
+}
+
+func TestComputeLineNumbers(t *testing.T) {
+	hunks := Compute("a\nb\nc", "a\nx\nc", 1)
+
+	assert.Equal(t, len(hunks), 1)
+
+	want := []Line{
+		{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: Delete, Text: "b", OldLine: 2},
+		{Op: Insert, Text: "x", NewLine: 2},
+		{Op: Equal, Text: "c", OldLine: 3, NewLine: 3},
+	}
+	assert.Equal(t, len(hunks[0].Lines), len(want))
+	for i := range want {
+		assert.Equal(t, hunks[0].Lines[i], want[i])
+	}
+}
@@ -0,0 +1,306 @@
+// Package migrate applies the versioned SQL migrations kept in the migrations
+// package. Applied versions are recorded in a schema_migrations table.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returned (wrapped) by Check when the database isn't at the latest version
+var ErrOutOfDate = errors.New("migrate: database schema is out of date")
+
+// A single migration, read from NNNN_name.up.sql and NNNN_name.down.sql
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// The state of a migration in a database
+type Status struct {
+	Migration
+	Applied time.Time // zero if the migration hasn't been applied
+}
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Reads the migrations in dir of fsys, sorted by version. Every migration
+// needs both an up and a down file.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		m := fileRegexp.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(b)
+		} else {
+			migration.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Splits the contents of a migration file into statements. Statements end
+// with a semicolon at the end of a line; "--" comment lines are dropped. This
+// is all our migrations need, so there's no real SQL parsing.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// Applies migrations to a database
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration // sorted by version, as returned by Load
+
+	// Query returning how many tables named schema_migrations there are. Each
+	// database lists its tables differently, and Status and Check need to
+	// know without creating the table: they only read.
+	CountTable string
+}
+
+// Creates the table recording the applied versions if it doesn't exist yet.
+// The SQL here and in run is understood by every database we support.
+func (m *Migrator) ensureTable() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied TIMESTAMP NOT NULL
+	)`
+
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+// Returns when each applied version was applied. Nothing has been applied to
+// a database without the schema_migrations table.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+
+	var tables int
+	err := m.DB.QueryRow(m.CountTable).Scan(&tables)
+	if err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return applied, nil
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err := rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Runs the statements of script and then record (or forget) the version in a
+// single transaction. Databases which commit DDL implicitly (like MySQL) can't
+// roll a failed migration back, so it may need cleaning up by hand.
+func (m *Migrator) run(version int, script string, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Databases disagree on placeholders (? or $1), so the version is
+	// formatted into the statement. It's an int, so this is safe.
+	if up {
+		_, err = tx.Exec(fmt.Sprintf(`INSERT INTO schema_migrations (version, applied) VALUES(%d, CURRENT_TIMESTAMP)`, version))
+	} else {
+		_, err = tx.Exec(fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %d`, version))
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Applies every migration which hasn't been applied yet, in order. Returns
+// the migrations which were applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	err := m.ensureTable()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.run(migration.Version, migration.Up, true)
+		if err != nil {
+			return done, fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Reverts the most recently applied migration and returns it, or nil if no
+// migration has been applied.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.run(migration.Version, migration.Down, false)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: reverting %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+
+	return nil, nil
+}
+
+// Lists every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.Migrations))
+	for i, migration := range m.Migrations {
+		statuses[i] = Status{Migration: migration, Applied: applied[migration.Version]}
+	}
+
+	return statuses, nil
+}
+
+// Returns an error wrapping ErrOutOfDate if any migration hasn't been applied,
+// or if the database has versions applied which this build doesn't know
+// about (it is newer than the code).
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	known := map[int]bool{}
+	for _, migration := range m.Migrations {
+		known[migration.Version] = true
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migration(s) pending", ErrOutOfDate, pending)
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: version %d is applied but unknown to this build", ErrOutOfDate, version)
+		}
+	}
+
+	return nil
+}
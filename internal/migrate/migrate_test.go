@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/0002_tags.up.sql":      {Data: []byte("CREATE TABLE tags (id INTEGER);")},
+		"db/0002_tags.down.sql":    {Data: []byte("DROP TABLE tags;")},
+		"db/0001_initial.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER);")},
+		"db/0001_initial.down.sql": {Data: []byte("DROP TABLE users;")},
+		"db/README.md":             {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(migrations), 2)
+	assert.Equal(t, migrations[0].Version, 1)
+	assert.Equal(t, migrations[0].Name, "initial")
+	assert.Equal(t, migrations[1].Version, 2)
+	assert.Equal(t, migrations[1].Down, "DROP TABLE tags;")
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Missing down",
+			fsys: fstest.MapFS{
+				"db/0001_initial.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+			},
+		},
+		{
+			name: "Duplicate version",
+			fsys: fstest.MapFS{
+				"db/0001_initial.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER);")},
+				"db/0001_initial.down.sql": {Data: []byte("DROP TABLE users;")},
+				"db/0001_other.up.sql":     {Data: []byte("CREATE TABLE tags (id INTEGER);")},
+				"db/0001_other.down.sql":   {Data: []byte("DROP TABLE tags;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys, "db")
+
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "Single",
+			script: "DROP TABLE users;\n",
+			want:   []string{"DROP TABLE users"},
+		},
+		{
+			name:   "Multi-line with comments",
+			script: "-- The users\nCREATE TABLE users (\n    id INTEGER\n);\n\nCREATE INDEX idx ON users(id);\n",
+			want:   []string{"CREATE TABLE users (\n    id INTEGER\n)", "CREATE INDEX idx ON users(id)"},
+		},
+		{
+			name:   "Missing final semicolon",
+			script: "DROP TABLE tags;\nDROP TABLE users",
+			want:   []string{"DROP TABLE tags", "DROP TABLE users"},
+		},
+		{
+			name:   "Empty",
+			script: "-- nothing to do\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+
+			assert.Equal(t, strings.Join(got, "|"), strings.Join(tt.want, "|"))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package memory implements the stores of the models package in memory, for
+// tests and throwaway demo instances. Everything is lost when the process
+// exits. The stores behave like the SQL ones, expiry and view limits included.
+package memory
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Holds the data of every store. Stores sharing a DB see each other's data,
+// just as the SQL stores share tables (snippets show their author's name,
+// for instance). Safe for concurrent use.
+type DB struct {
+	mu        sync.Mutex
+	users     map[int]*models.User
+	snippets  map[int]*models.Snippet // AuthorName and Forks are filled in when read
+	revisions map[int][]*models.Revision
+	tokens    map[int]*token
+	lastID    struct{ user, snippet, token int } // like AUTO_INCREMENT
+
+	// Returns the current time, tests may replace it to move the clock on
+	Now func() time.Time
+}
+
+// A token as stored, with the hash of its plaintext
+type token struct {
+	models.Token
+	hash string
+}
+
+// Returns a new, empty DB
+func New() *DB {
+	return &DB{
+		users:     map[int]*models.User{},
+		snippets:  map[int]*models.Snippet{},
+		revisions: map[int][]*models.Revision{},
+		tokens:    map[int]*token{},
+		Now:       time.Now,
+	}
+}
+
+// Returns the current time in UTC, as the SQL stores store it
+func (db *DB) now() time.Time {
+	return db.Now().UTC()
+}
+
+// Converts a stored time to UTC, leaving the zero time (never) alone
+func utc(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+// Reports whether s has expired, either because its time is up or because
+// it has been read as often as it may be (the live condition of the SQL
+// stores, negated)
+func (db *DB) expired(s *models.Snippet) bool {
+	return (!s.Expires.IsZero() && !s.Expires.After(db.now())) || s.UsedUp()
+}
+
+// Returns a copy of s as the SQL stores would return it. Callers get copies
+// so they can't change the stored snippet behind our back. Must be called
+// with mu held.
+func (db *DB) read(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Tags = slices.Clone(s.Tags)
+	c.PasswordHash = slices.Clone(s.PasswordHash)
+
+	if u, ok := db.users[s.AuthorID]; ok {
+		c.AuthorName = u.Name
+	}
+	for _, f := range db.snippets {
+		if f.ParentID == s.ID {
+			c.Forks++
+		}
+	}
+	return &c
+}
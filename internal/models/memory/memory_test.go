@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Returns a DB with one user (id 1) and n public snippets by them, ids 1 to n
+func newTestDB(t *testing.T, n int) *DB {
+	db := New()
+	db.users[1] = &models.User{ID: 1, Name: "Alice", Email: "alice@example.com"}
+	db.lastID.user = 1
+
+	snippets := &SnippetModel{DB: db}
+	for i := 1; i <= n; i++ {
+		_, err := snippets.Insert(&models.Snippet{Title: fmt.Sprint("Snippet ", i), Visibility: models.VisibilityPublic, AuthorID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// Returns the ids of snippets, for comparing
+func ids(snippets []*models.Snippet) string {
+	s := ""
+	for _, snippet := range snippets {
+		s += fmt.Sprint(snippet.ID, " ")
+	}
+	return s
+}
+
+func TestSnippetModelPages(t *testing.T) {
+	snippets := &SnippetModel{DB: newTestDB(t, 5)}
+
+	tests := []struct {
+		name string
+		page models.Page
+		want string
+	}{
+		{name: "First", page: models.Page{Limit: 2}, want: "5 4 "},
+		{name: "Before", page: models.Page{Before: 4, Limit: 2}, want: "3 2 "},
+		{name: "Last", page: models.Page{Before: 2, Limit: 2}, want: "1 "},
+		{name: "After", page: models.Page{After: 2, Limit: 2}, want: "4 3 "},
+		{name: "After near the start", page: models.Page{After: 4, Limit: 2}, want: "5 "},
+		{name: "Missing cursor", page: models.Page{Before: 9, Limit: 2}, want: "5 4 "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snippets.Latest(tt.page)
+
+			assert.Equal(t, err, nil)
+			assert.Equal(t, ids(got), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	db := newTestDB(t, 0)
+	snippets := &SnippetModel{DB: db}
+
+	now := time.Now()
+	db.Now = func() time.Time { return now }
+
+	id, err := snippets.Insert(&models.Snippet{Title: "Soon gone", Visibility: models.VisibilityPublic, AuthorID: 1, Expires: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkID, err := snippets.Fork(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippets.Renew(forkID, time.Time{})
+
+	s, err := snippets.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.Views, 1)
+	assert.Equal(t, s.Forks, 1)
+	assert.Equal(t, s.AuthorName, "Alice")
+
+	// An hour later the original has expired but the fork, renewed to never
+	// expire, hasn't
+	now = now.Add(time.Hour)
+	_, err = snippets.Peek(id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, _ := snippets.Latest(models.Page{Limit: 10})
+	assert.Equal(t, ids(latest), fmt.Sprint(forkID, " "))
+
+	n, err := snippets.DeleteExpired(10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 1)
+
+	fork, err := snippets.Peek(forkID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, fork.ParentID, 0)
+}
+
+func TestSnippetModelMaxViews(t *testing.T) {
+	snippets := &SnippetModel{DB: newTestDB(t, 0)}
+
+	id, err := snippets.Insert(&models.Snippet{Title: "Secret", Visibility: models.VisibilityUnlisted, AuthorID: 1, MaxViews: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		s, err := snippets.Get(id)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, s.Views, i)
+	}
+
+	_, err = snippets.Get(id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	snippets := &SnippetModel{DB: newTestDB(t, 0)}
+
+	insert := func(s *models.Snippet) {
+		s.AuthorID = 1
+		_, err := snippets.Insert(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(&models.Snippet{Title: "Connection pool", Content: "db.SetMaxOpenConns(10)", Visibility: models.VisibilityPublic})
+	insert(&models.Snippet{Title: "Pool party", Content: "no connections here", Visibility: models.VisibilityPublic})
+	insert(&models.Snippet{Title: "Private pool", Content: "connection", Visibility: models.VisibilityPrivate})
+	insert(&models.Snippet{Title: "Protected pool", Content: "connection", Visibility: models.VisibilityPublic, PasswordHash: []byte("x")})
+
+	found, err := snippets.Search("POOL connection", 10, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ids(found), "2 1 ")
+
+	found, err = snippets.Search("pool", 1, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ids(found), "1 ")
+}
+
+func TestTokenModel(t *testing.T) {
+	tokens := &TokenModel{DB: newTestDB(t, 0)}
+
+	plaintext, err := tokens.Insert(1, "ci", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokens.Authenticate(plaintext)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, token.LastUsed.IsZero(), true)
+
+	list, _ := tokens.ForUser(1)
+	assert.Equal(t, len(list), 1)
+	assert.Equal(t, list[0].LastUsed.IsZero(), false)
+
+	err = tokens.Delete(token.ID, 2)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	err = tokens.Delete(token.ID, 1)
+	assert.Equal(t, err, nil)
+
+	_, err = tokens.Authenticate(plaintext)
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+}
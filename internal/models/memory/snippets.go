@@ -0,0 +1,354 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Stores snippets in a DB
+type SnippetModel struct {
+	DB *DB
+}
+
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// Returns the tags to store: sorted by name (as the SQL stores return them)
+// without duplicates
+func sortedTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// Records the current state of a snippet as its next revision. Must be
+// called with mu held.
+func (db *DB) addRevision(s *models.Snippet) {
+	db.revisions[s.ID] = append(db.revisions[s.ID], &models.Revision{
+		SnippetID: s.ID,
+		Number:    len(db.revisions[s.ID]) + 1,
+		Title:     s.Title,
+		Content:   s.Content,
+		Language:  s.Language,
+		Markdown:  s.Markdown,
+		Created:   db.now(),
+	})
+}
+
+// Inserts a new snippet, see models.SnippetStore.
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.users[s.AuthorID]; !ok {
+		return 0, fmt.Errorf("memory: snippet author %d doesn't exist", s.AuthorID)
+	}
+
+	m.DB.lastID.snippet++
+	stored := &models.Snippet{
+		ID:           m.DB.lastID.snippet,
+		Title:        s.Title,
+		Content:      s.Content,
+		Language:     s.Language,
+		Markdown:     s.Markdown,
+		Created:      m.DB.now(),
+		Expires:      utc(s.Expires),
+		AuthorID:     s.AuthorID,
+		Tags:         sortedTags(s.Tags),
+		Visibility:   s.Visibility,
+		Slug:         slug,
+		PasswordHash: slices.Clone(s.PasswordHash),
+		MaxViews:     s.MaxViews,
+	}
+	m.DB.snippets[stored.ID] = stored
+	m.DB.addRevision(stored)
+
+	return stored.ID, nil
+}
+
+// Replaces the editable fields of the snippet with s.ID, see models.SnippetStore.
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	stored, ok := m.DB.snippets[s.ID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	stored.Title = s.Title
+	stored.Content = s.Content
+	stored.Language = s.Language
+	stored.Markdown = s.Markdown
+	stored.Visibility = s.Visibility
+	stored.PasswordHash = slices.Clone(s.PasswordHash)
+	stored.MaxViews = s.MaxViews
+	stored.Expires = utc(s.Expires)
+	stored.Tags = sortedTags(s.Tags)
+	m.DB.addRevision(stored)
+
+	return nil
+}
+
+// Copies the snippet with the given id into a new snippet owned by userID, see
+// models.SnippetStore.
+func (m *SnippetModel) Fork(id int, userID int) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	original, ok := m.DB.snippets[id]
+	if !ok || m.DB.expired(original) {
+		return 0, models.ErrNoRecord
+	}
+
+	m.DB.lastID.snippet++
+	fork := &models.Snippet{
+		ID:           m.DB.lastID.snippet,
+		Title:        original.Title,
+		Content:      original.Content,
+		Language:     original.Language,
+		Markdown:     original.Markdown,
+		Created:      m.DB.now(),
+		Expires:      original.Expires,
+		AuthorID:     userID,
+		Tags:         slices.Clone(original.Tags),
+		ParentID:     original.ID,
+		Visibility:   original.Visibility,
+		Slug:         slug,
+		PasswordHash: slices.Clone(original.PasswordHash),
+		MaxViews:     original.MaxViews,
+	}
+	m.DB.snippets[fork.ID] = fork
+	m.DB.addRevision(fork)
+
+	return fork.ID, nil
+}
+
+// Sets a new expiry time for the snippet with the given id, the zero time
+// meaning never.
+func (m *SnippetModel) Renew(id int, expires time.Time) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Expires = utc(expires)
+	return nil
+}
+
+// Removes a snippet and its revisions. Its forks are kept but lose their
+// parent, like ON DELETE SET NULL. Must be called with mu held.
+func (db *DB) deleteSnippet(id int) {
+	delete(db.snippets, id)
+	delete(db.revisions, id)
+	for _, s := range db.snippets {
+		if s.ParentID == id {
+			s.ParentID = 0
+		}
+	}
+}
+
+// Removes the snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+	m.DB.deleteSnippet(id)
+	return nil
+}
+
+// Removes up to limit expired snippets, oldest first.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	var ids []int
+	for id, s := range m.DB.snippets {
+		if m.DB.expired(s) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	for _, id := range ids {
+		m.DB.deleteSnippet(id)
+	}
+	return len(ids), nil
+}
+
+// Reads the snippet with the given id, counting it as a view. Once a snippet
+// with a view limit has been read MaxViews times it is treated as expired.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || m.DB.expired(s) {
+		return nil, models.ErrNoRecord
+	}
+
+	s.Views++
+	return m.DB.read(s), nil
+}
+
+// Returns the snippet with the given id without counting a view.
+func (m *SnippetModel) Peek(id int) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || m.DB.expired(s) {
+		return nil, models.ErrNoRecord
+	}
+	return m.DB.read(s), nil
+}
+
+// Returns the snippet with the given share link slug without counting a view.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && !m.DB.expired(s) {
+			return m.DB.read(s), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// Returns a page of the most recently created public snippets.
+func (m *SnippetModel) Latest(page models.Page) ([]*models.Snippet, error) {
+	return m.list(func(*models.Snippet) bool { return true }, page), nil
+}
+
+// Returns a page of the most recently created public snippets tagged with tag.
+func (m *SnippetModel) ByTag(tag string, page models.Page) ([]*models.Snippet, error) {
+	return m.list(func(s *models.Snippet) bool { return slices.Contains(s.Tags, tag) }, page), nil
+}
+
+// Returns the unexpired public snippets for which match returns true, newest
+// first
+func (db *DB) public(match func(*models.Snippet) bool) []*models.Snippet {
+	var matched []*models.Snippet
+	for _, s := range db.snippets {
+		if s.Visibility == models.VisibilityPublic && !db.expired(s) && match(s) {
+			matched = append(matched, s)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *models.Snippet) int { return b.ID - a.ID })
+	return matched
+}
+
+// Returns a page of the unexpired public snippets for which match returns
+// true, newest first
+func (m *SnippetModel) list(match func(*models.Snippet) bool, page models.Page) []*models.Snippet {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	matched := m.DB.public(match)
+
+	// Cut the window out of the newest first list: the Limit snippets
+	// closest to the cursor on the requested side
+	start, end := 0, len(matched)
+	switch {
+	case page.After > 0:
+		end, _ = slices.BinarySearchFunc(matched, page.After, func(s *models.Snippet, id int) int { return id - s.ID })
+		start = max(0, end-page.Limit)
+	case page.Before > 0:
+		start, _ = slices.BinarySearchFunc(matched, page.Before, func(s *models.Snippet, id int) int { return id - s.ID })
+		if start < len(matched) && matched[start].ID == page.Before {
+			start++
+		}
+	}
+	end = min(end, start+page.Limit)
+
+	snippets := []*models.Snippet{}
+	for _, s := range matched[start:end] {
+		snippets = append(snippets, m.DB.read(s))
+	}
+	return snippets
+}
+
+// Returns the public, unprotected snippets whose title or content contain
+// every word of query (ignoring case), newest first. At most limit snippets
+// are returned, after skipping the first offset matches.
+func (m *SnippetModel) Search(query string, limit int, offset int) ([]*models.Snippet, error) {
+	words := strings.Fields(strings.ToLower(query))
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	matched := m.DB.public(func(s *models.Snippet) bool {
+		if s.PasswordHash != nil || s.MaxViews > 0 {
+			return false
+		}
+		title, content := strings.ToLower(s.Title), strings.ToLower(s.Content)
+		for _, word := range words {
+			if !strings.Contains(title, word) && !strings.Contains(content, word) {
+				return false
+			}
+		}
+		return true
+	})
+
+	snippets := []*models.Snippet{}
+	for i := offset; i < len(matched) && i < offset+limit; i++ {
+		snippets = append(snippets, m.DB.read(matched[i]))
+	}
+	return snippets, nil
+}
+
+// Returns every revision of a snippet, newest first.
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	revisions := []*models.Revision{}
+	stored := m.DB.revisions[id]
+	for i := len(stored) - 1; i >= 0; i-- {
+		c := *stored[i]
+		revisions = append(revisions, &c)
+	}
+	return revisions, nil
+}
+
+// Returns revision n of a snippet.
+func (m *SnippetModel) Revision(id int, n int) (*models.Revision, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	revisions := m.DB.revisions[id]
+	if n < 1 || n > len(revisions) {
+		return nil, models.ErrNoRecord
+	}
+	c := *revisions[n-1]
+	return &c, nil
+}
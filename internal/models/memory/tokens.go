@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"slices"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Stores API tokens in a DB
+type TokenModel struct {
+	DB *DB
+}
+
+// Creates a new token for the user with the given id and returns its
+// plaintext.
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	plaintext, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastID.token++
+	t := &token{
+		Token: models.Token{
+			ID:      m.DB.lastID.token,
+			UserID:  userID,
+			Name:    name,
+			Scope:   scope,
+			Created: m.DB.now(),
+		},
+		hash: string(hash),
+	}
+	m.DB.tokens[t.ID] = t
+
+	return plaintext, nil
+}
+
+// Returns the tokens of the user with the given id, newest first.
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			c := t.Token
+			tokens = append(tokens, &c)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *models.Token) int { return b.ID - a.ID })
+
+	return tokens, nil
+}
+
+// Revokes the token with the given id if it belongs to the user with userID.
+// Returns models.ErrNoRecord otherwise.
+func (m *TokenModel) Delete(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+	delete(m.DB.tokens, id)
+	return nil
+}
+
+// Looks up the token with the given plaintext and records that it has been
+// used. Returns models.ErrInvalidCredentials if there is no such token.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	hash := string(models.HashToken(plaintext))
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, t := range m.DB.tokens {
+		if t.hash == hash {
+			// Like the SQL stores, return the token as it was before this use
+			c := t.Token
+			t.LastUsed = m.DB.now()
+			return &c, nil
+		}
+	}
+	return nil, models.ErrInvalidCredentials
+}
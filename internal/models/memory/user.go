@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"errors"
+
+	"snippetbox.victorsmith.dev/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Stores users in a DB
+type UserModel struct {
+	DB *DB
+}
+
+// Adds a user, returning models.ErrDuplicateEmail if the email is taken
+func (m *UserModel) Insert(name, email, password string) error {
+	// Hashed before taking the lock, bcrypt is slow on purpose
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.DB.lastID.user++
+	m.DB.users[m.DB.lastID.user] = &models.User{
+		ID:             m.DB.lastID.user,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hash,
+		Created:        m.DB.now(),
+	}
+	return nil
+}
+
+// Returns the id of the user with the given email and password, or
+// models.ErrInvalidCredentials if there is no such user
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.DB.mu.Lock()
+	var user *models.User
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			user = u
+			break
+		}
+	}
+	m.DB.mu.Unlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	// Users are never changed once added, so the hash can be read unlocked
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return user.ID, nil
+}
+
+// Reports whether a user with the given id exists
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	_, ok := m.DB.users[id]
+	return ok, nil
+}
@@ -0,0 +1,140 @@
+// Package mocks implements the stores of the models package with canned data,
+// so handlers can be tested without a database. There is one snippet (id 1,
+// public, by user 1) and one user (id 1, alice@example.com). Nothing that is
+// written is kept: inserts return fixed ids and updates are ignored.
+package mocks
+
+import (
+	"slices"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// The only snippet the mock store knows about
+var mockSnippet = &models.Snippet{
+	ID:         1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	AuthorID:   1,
+	AuthorName: "Alice",
+	Tags:       []string{"haiku"},
+	Visibility: models.VisibilityPublic,
+	Slug:       "mock-slug",
+}
+
+// Id returned for every snippet added to the store
+const insertedSnippetID = 2
+
+// Stores snippets, see the package doc for what it holds
+type SnippetModel struct{}
+
+// Returns a copy of the mock snippet, so callers can't change it for the
+// tests that follow
+func snippet() *models.Snippet {
+	s := *mockSnippet
+	s.Tags = slices.Clone(mockSnippet.Tags)
+	return &s
+}
+
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	return insertedSnippetID, nil
+}
+
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	return m.exists(s.ID)
+}
+
+func (m *SnippetModel) Fork(id int, userID int) (int, error) {
+	if err := m.exists(id); err != nil {
+		return 0, err
+	}
+	return insertedSnippetID, nil
+}
+
+func (m *SnippetModel) Renew(id int, expires time.Time) error {
+	return m.exists(id)
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	return m.exists(id)
+}
+
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	return 0, nil
+}
+
+// Returns models.ErrNoRecord unless id is the mock snippet's
+func (m *SnippetModel) exists(id int) error {
+	if id != mockSnippet.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.Peek(id)
+}
+
+func (m *SnippetModel) Peek(id int) (*models.Snippet, error) {
+	if err := m.exists(id); err != nil {
+		return nil, err
+	}
+	return snippet(), nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	if slug != mockSnippet.Slug {
+		return nil, models.ErrNoRecord
+	}
+	return snippet(), nil
+}
+
+func (m *SnippetModel) Latest(page models.Page) ([]*models.Snippet, error) {
+	return []*models.Snippet{snippet()}, nil
+}
+
+func (m *SnippetModel) ByTag(tag string, page models.Page) ([]*models.Snippet, error) {
+	if !slices.Contains(mockSnippet.Tags, tag) {
+		return []*models.Snippet{}, nil
+	}
+	return []*models.Snippet{snippet()}, nil
+}
+
+func (m *SnippetModel) Search(query string, limit int, offset int) ([]*models.Snippet, error) {
+	if offset > 0 || limit < 1 {
+		return []*models.Snippet{}, nil
+	}
+	return []*models.Snippet{snippet()}, nil
+}
+
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	r, err := m.Revision(id, 1)
+	if err != nil {
+		return []*models.Revision{}, nil
+	}
+	return []*models.Revision{r}, nil
+}
+
+func (m *SnippetModel) Revision(id int, n int) (*models.Revision, error) {
+	if id != mockSnippet.ID || n != 1 {
+		return nil, models.ErrNoRecord
+	}
+	return &models.Revision{
+		SnippetID: mockSnippet.ID,
+		Number:    1,
+		Title:     mockSnippet.Title,
+		Content:   mockSnippet.Content,
+		Language:  mockSnippet.Language,
+		Created:   mockSnippet.Created,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Plaintext of the only token the mock store knows about: a write token of
+// user 1 with id 1
+const Token = "mock-token"
+
+// Stores API tokens, see the package doc for what it holds
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	return Token, nil
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	if userID != 1 {
+		return []*models.Token{}, nil
+	}
+	return []*models.Token{token()}, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	if id != 1 || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	if plaintext != Token {
+		return nil, models.ErrInvalidCredentials
+	}
+	return token(), nil
+}
+
+func token() *models.Token {
+	return &models.Token{ID: 1, UserID: 1, Name: "mock", Scope: models.ScopeWrite, Created: time.Now()}
+}
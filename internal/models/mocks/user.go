@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Credentials of the only user the mock store knows about, whose id is 1
+const (
+	UserEmail    = "alice@example.com"
+	UserPassword = "pa$$word"
+)
+
+// Signing up with this email fails with models.ErrDuplicateEmail
+const DuplicateEmail = "dupe@example.com"
+
+// Stores users, see the package doc for what it holds
+type UserModel struct{}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	if email == DuplicateEmail {
+		return models.ErrDuplicateEmail
+	}
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	if email == UserEmail && password == UserPassword {
+		return 1, nil
+	}
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	return id == 1, nil
+}
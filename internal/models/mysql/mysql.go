@@ -0,0 +1,32 @@
+// Package mysql implements the stores of the models package on MySQL.
+// Databases have to be opened with Open.
+package mysql
+
+import (
+	"database/sql"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// Opens a connection pool to the MySQL database at dsn and checks that it can
+// be reached. Whatever the DSN says, DATETIME columns scan into time.Time and
+// statements report the rows they matched rather than those they changed, so
+// an update which changes nothing isn't mistaken for a missing row.
+func Open(dsn string) (*sql.DB, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ParseTime = true
+	cfg.ClientFoundRows = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Returns every revision of a snippet, newest first.
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT snippet_id, revision, title, content, language, markdown, created
+	FROM snippet_revisions WHERE snippet_id = ? ORDER BY revision DESC`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		r := &models.Revision{}
+		err := rows.Scan(&r.SnippetID, &r.Number, &r.Title, &r.Content, &r.Language, &r.Markdown, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Returns revision n of a snippet.
+func (m *SnippetModel) Revision(id int, n int) (*models.Revision, error) {
+	stmt := `SELECT snippet_id, revision, title, content, language, markdown, created
+	FROM snippet_revisions WHERE snippet_id = ? AND revision = ?`
+
+	r := &models.Revision{}
+	err := m.DB.QueryRow(stmt, id, n).Scan(&r.SnippetID, &r.Number, &r.Title, &r.Content, &r.Language, &r.Markdown, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,509 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// Wraps the connection pool
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// Start of every query returning snippets. The author's name is pulled in from
+// the users table so templates can show who wrote each snippet, the tag names
+// are collapsed into one comma separated column (tags can't contain commas)
+// and forks are counted.
+// Must stay in sync with scanSnippet.
+const selectSnippets = `SELECT snippets.id, snippets.title, snippets.content, snippets.language, snippets.markdown, snippets.created, snippets.expires,
+	snippets.user_id, users.name,
+	(SELECT GROUP_CONCAT(tags.name ORDER BY tags.name) FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id
+		WHERE snippet_tags.snippet_id = snippets.id),
+	COALESCE(snippets.parent_id, 0),
+	(SELECT COUNT(*) FROM snippets AS forks WHERE forks.parent_id = snippets.id),
+	snippets.visibility, snippets.slug, snippets.password_hash, snippets.views, snippets.max_views
+	FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id`
+
+// Snippets which haven't expired, either because their time is up or because
+// they have been read as often as they may be.
+const live = ` WHERE (snippets.expires IS NULL OR snippets.expires > UTC_TIMESTAMP())
+	AND (snippets.max_views = 0 OR snippets.views < snippets.max_views)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans a row selected with selectSnippets into a new Snippet
+func scanSnippet(row rowScanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var tags sql.NullString
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Language, &s.Markdown, &s.Created, &expires, &s.AuthorID, &s.AuthorName, &tags,
+		&s.ParentID, &s.Forks, &s.Visibility, &s.Slug, &s.PasswordHash, &s.Views, &s.MaxViews)
+	if err != nil {
+		return nil, err
+	}
+	// NULL (never expires) is left as the zero time
+	s.Expires = expires.Time
+	if tags.Valid {
+		s.Tags = strings.Split(tags.String, ",")
+	}
+	return s, nil
+}
+
+// Scans every row selected with selectSnippets
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	// use rows.Next to iterate through all results
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	// Get all errors encounterd in iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Converts an expiry time for storage: the zero time (never) becomes NULL.
+// Times are stored in UTC to match UTC_TIMESTAMP().
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// This will insert a new snippet into the database. The title, content,
+// language, format, visibility, password, view limit, tags, expiry and author
+// (AuthorID) are taken from s. Every snippet is given a slug so its visibility
+// can be changed later on.
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	// The snippet and its tags are written together or not at all
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id) 
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?)`
+
+	res, err := tx.Exec(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, slug, s.PasswordHash, s.MaxViews, nullTime(s.Expires), s.AuthorID)
+	if err != nil {
+		return 0, err
+	}
+
+	// get id of newly inserted entry
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, int(id))
+	if err != nil {
+		return 0, err
+	}
+
+	// convert id to int => return values
+	return int(id), tx.Commit()
+}
+
+// Replaces the title, content, language, format, visibility, password, view
+// limit, tags and expiry of the snippet with s.ID. Views already counted are kept.
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, markdown = ?, visibility = ?,
+	password_hash = ?, max_views = ?, expires = ? WHERE id = ?`
+
+	// Open has the driver count the rows matched, so a missing snippet is
+	// reported even if the edit changes nothing
+	res, err := tx.Exec(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, s.PasswordHash, s.MaxViews, nullTime(s.Expires), s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Copies the snippet with the given id (content, format, visibility, password,
+// view limit, tags and expiry) into a new snippet owned by userID, recording the
+// original as its parent. The fork gets its own slug and starts without views. Returns the id of the new snippet.
+func (m *SnippetModel) Fork(id int, userID int) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id, parent_id)
+	SELECT title, content, language, markdown, visibility, ?, password_hash, max_views, UTC_TIMESTAMP(), expires, ?, id
+	FROM snippets` + live + ` AND id = ?`
+
+	res, err := tx.Exec(stmt, slug, userID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nothing was copied if the original doesn't exist (or has expired)
+	err = checkAffected(res)
+	if err != nil {
+		return 0, err
+	}
+
+	forkID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT ?, tag_id FROM snippet_tags WHERE snippet_id = ?`
+
+	_, err = tx.Exec(stmt, forkID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, int(forkID))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(forkID), tx.Commit()
+}
+
+// Sets a new expiry time for the snippet with the given id, the zero time
+// meaning never. Nothing else changes, so this doesn't add a revision.
+func (m *SnippetModel) Renew(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ?`
+
+	res, err := m.DB.Exec(stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Replaces the tags attached to a snippet, creating any tags which don't exist yet.
+func setTags(tx *sql.Tx, id int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// LAST_INSERT_ID(id) makes LastInsertId return the existing tag's id
+		// when the name is already taken
+		res, err := tx.Exec(`INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, tag)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)`, id, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records the current state of a snippet as its next revision. Called after
+// every insert and update so the latest revision always matches the snippet.
+func addRevision(tx *sql.Tx, id int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, language, markdown, created)
+	SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?),
+		title, content, language, markdown, UTC_TIMESTAMP()
+	FROM snippets WHERE id = ?`
+
+	_, err := tx.Exec(stmt, id, id)
+	return err
+}
+
+// Removes the snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	res, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Removes up to limit snippets which have expired, either because their time
+// is up or because they have been read as often as they may be, oldest first.
+// Returns the number of snippets removed; callers keep going until that is
+// less than limit. Deleting in batches keeps each statement (and the locks it
+// holds) short.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets
+	WHERE expires <= UTC_TIMESTAMP() OR (max_views > 0 AND views >= max_views)
+	ORDER BY id LIMIT ?`
+
+	res, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Returns models.ErrNoRecord if a statement didn't touch any rows
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Reads the snippet with the given id, counting it as a view. Once a snippet
+// with a view limit has been read MaxViews times it is treated as expired.
+//
+// The view is claimed by the UPDATE before the snippet is selected, so of two
+// concurrent reads of a snippet with a single view left only one gets it; the
+// other gets models.ErrNoRecord.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+	WHERE id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()) AND (max_views = 0 OR views < max_views)`
+
+	res, err := tx.Exec(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return nil, err
+	}
+
+	// No expiry check here: this may have been the last view
+	s, err := scanSnippet(tx.QueryRow(selectSnippets+` WHERE snippets.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, tx.Commit()
+}
+
+// Returns the snippet with the given id without counting a view.
+func (m *SnippetModel) Peek(id int) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.id = ?`
+
+	// returns a pointer to a sql.Row object which holds the result
+	row := m.DB.QueryRow(stmt, id)
+	s, err := scanSnippet(row)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Returns the snippet with the given share link slug, whatever its visibility.
+// Like Peek this doesn't count a view.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// This will return a page of the most recently created public snippets.
+func (m *SnippetModel) Latest(page models.Page) ([]*models.Snippet, error) {
+	return m.list("", nil, page)
+}
+
+// Returns a page of the most recently created public snippets tagged with tag.
+func (m *SnippetModel) ByTag(tag string, page models.Page) ([]*models.Snippet, error) {
+	where := ` AND snippets.id IN (SELECT snippet_tags.snippet_id FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id WHERE tags.name = ?)`
+
+	return m.list(where, []any{tag}, page)
+}
+
+// Returns a page of unexpired public snippets, newest first. where is appended
+// to the WHERE clause (and must start with AND) to narrow the list down further.
+func (m *SnippetModel) list(where string, args []any, page models.Page) ([]*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.visibility = 'public'` + where
+
+	// When paging forwards walk the index in ascending order so that LIMIT
+	// keeps the snippets closest to the cursor, then flip them round below.
+	switch {
+	case page.After > 0:
+		stmt += ` AND snippets.id > ? ORDER BY snippets.id ASC LIMIT ?`
+		args = append(args, page.After, page.Limit)
+	case page.Before > 0:
+		stmt += ` AND snippets.id < ? ORDER BY snippets.id DESC LIMIT ?`
+		args = append(args, page.Before, page.Limit)
+	default:
+		stmt += ` ORDER BY snippets.id DESC LIMIT ?`
+		args = append(args, page.Limit)
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After > 0 {
+		slices.Reverse(snippets)
+	}
+
+	return snippets, nil
+}
+
+// Snippets which may turn up in search results. Password protected snippets
+// and snippets with a view limit are left out since the results quote their
+// content.
+const searchable = live + ` AND snippets.visibility = 'public' AND snippets.password_hash IS NULL AND snippets.max_views = 0`
+
+// Returns the public, unprotected snippets whose title or content match query, best matches first.
+// At most limit snippets are returned, after skipping the first offset matches.
+//
+// This relies on a FULLTEXT index over (title, content). If the index is
+// missing we fall back to a (much slower) substring match, so search keeps
+// working on databases that haven't been given the index.
+func (m *SnippetModel) Search(query string, limit int, offset int) ([]*models.Snippet, error) {
+	stmt := selectSnippets + searchable + `
+	AND MATCH(snippets.title, snippets.content) AGAINST(? IN NATURAL LANGUAGE MODE)
+	ORDER BY MATCH(snippets.title, snippets.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, snippets.id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, query, query, limit, offset)
+	if err != nil {
+		// 1191: Can't find FULLTEXT index matching the column list
+		var mySQLError *mysqldriver.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1191 {
+			return m.searchLike(query, limit, offset)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// Substring search used when there is no FULLTEXT index. Every word in the
+// query has to appear in either the title or the content.
+func (m *SnippetModel) searchLike(query string, limit int, offset int) ([]*models.Snippet, error) {
+	stmt := selectSnippets + searchable
+
+	var args []any
+	for _, word := range strings.Fields(query) {
+		stmt += ` AND (snippets.title LIKE ? OR snippets.content LIKE ?)`
+		pattern := "%" + escapeLike(word) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	stmt += ` ORDER BY snippets.id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// Escapes the LIKE wildcards in s so they are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
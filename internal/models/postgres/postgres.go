@@ -0,0 +1,49 @@
+// Package postgres implements the stores of the models package on PostgreSQL,
+// through the database/sql driver of github.com/jackc/pgx ("pgx").
+package postgres
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Converts an expiry time for storage: the zero time (never) becomes NULL.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// Converts a password hash for storage: nil (no password) becomes NULL rather
+// than an empty bytea.
+func nullBytes(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// Appends arg to args and returns its placeholder ($1, $2, ...). Used to
+// build statements whose number of arguments varies.
+func param(args *[]any, arg any) string {
+	*args = append(*args, arg)
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// Returns models.ErrNoRecord if a statement didn't touch any rows
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
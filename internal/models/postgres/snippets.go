@@ -0,0 +1,413 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Wraps the connection pool
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// Start of every query returning snippets, see the MySQL version for the
+// details. Must stay in sync with scanSnippet.
+const selectSnippets = `SELECT snippets.id, snippets.title, snippets.content, snippets.language, snippets.markdown, snippets.created, snippets.expires,
+	snippets.user_id, users.name,
+	(SELECT string_agg(tags.name, ',' ORDER BY tags.name) FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id
+		WHERE snippet_tags.snippet_id = snippets.id),
+	COALESCE(snippets.parent_id, 0),
+	(SELECT COUNT(*) FROM snippets AS forks WHERE forks.parent_id = snippets.id),
+	snippets.visibility, snippets.slug, snippets.password_hash, snippets.views, snippets.max_views
+	FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id`
+
+// Snippets which haven't expired, either because their time is up or because
+// they have been read as often as they may be.
+const live = ` WHERE (snippets.expires IS NULL OR snippets.expires > NOW())
+	AND (snippets.max_views = 0 OR snippets.views < snippets.max_views)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans a row selected with selectSnippets into a new Snippet
+func scanSnippet(row rowScanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var tags sql.NullString
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Language, &s.Markdown, &s.Created, &expires, &s.AuthorID, &s.AuthorName, &tags,
+		&s.ParentID, &s.Forks, &s.Visibility, &s.Slug, &s.PasswordHash, &s.Views, &s.MaxViews)
+	if err != nil {
+		return nil, err
+	}
+	// NULL (never expires) is left as the zero time
+	s.Expires = expires.Time
+	if tags.Valid {
+		s.Tags = strings.Split(tags.String, ",")
+	}
+	return s, nil
+}
+
+// Scans every row selected with selectSnippets
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Inserts a new snippet, see models.SnippetStore.
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The driver has no LastInsertId, so the id is returned by the INSERT
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, NOW(), $9, $10) RETURNING id`
+
+	var id int
+	err = tx.QueryRow(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, slug, nullBytes(s.PasswordHash), s.MaxViews, nullTime(s.Expires), s.AuthorID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = setTags(tx, id, s.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// Replaces the editable fields of the snippet with s.ID, see models.SnippetStore.
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = $1, content = $2, language = $3, markdown = $4, visibility = $5,
+	password_hash = $6, max_views = $7, expires = $8 WHERE id = $9`
+
+	// PostgreSQL counts the rows matched, so a missing snippet is reported
+	// even if the edit changes nothing
+	res, err := tx.Exec(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, nullBytes(s.PasswordHash), s.MaxViews, nullTime(s.Expires), s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Copies the snippet with the given id into a new snippet owned by userID, see
+// models.SnippetStore.
+func (m *SnippetModel) Fork(id int, userID int) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id, parent_id)
+	SELECT title, content, language, markdown, visibility, $1, password_hash, max_views, NOW(), expires, $2, id
+	FROM snippets` + live + ` AND id = $3 RETURNING id`
+
+	// Nothing is copied (and no id returned) if the original doesn't exist
+	// or has expired
+	var forkID int
+	err = tx.QueryRow(stmt, slug, userID, id).Scan(&forkID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT $1, tag_id FROM snippet_tags WHERE snippet_id = $2`
+
+	_, err = tx.Exec(stmt, forkID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, forkID)
+	if err != nil {
+		return 0, err
+	}
+
+	return forkID, tx.Commit()
+}
+
+// Sets a new expiry time for the snippet with the given id, the zero time
+// meaning never.
+func (m *SnippetModel) Renew(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = $1 WHERE id = $2`
+
+	res, err := m.DB.Exec(stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Replaces the tags attached to a snippet, creating any tags which don't exist yet.
+func setTags(tx *sql.Tx, id int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// The no-op update makes RETURNING give the existing tag's id when
+		// the name is already taken (DO NOTHING would return no row)
+		var tagID int
+		err := tx.QueryRow(`INSERT INTO tags (name) VALUES($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`, tag).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES($1, $2)`, id, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records the current state of a snippet as its next revision.
+func addRevision(tx *sql.Tx, id int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, language, markdown, created)
+	SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = $1),
+		title, content, language, markdown, NOW()
+	FROM snippets WHERE id = $1`
+
+	_, err := tx.Exec(stmt, id)
+	return err
+}
+
+// Removes the snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = $1`
+
+	res, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Removes up to limit expired snippets, oldest first. PostgreSQL has no
+// DELETE ... LIMIT, so the ids are picked by a subquery.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets
+		WHERE expires <= NOW() OR (max_views > 0 AND views >= max_views)
+		ORDER BY id LIMIT $1)`
+
+	res, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Reads the snippet with the given id, counting it as a view. A concurrent
+// UPDATE of the same row waits for this transaction and then checks the view
+// limit again, so of two reads of a snippet with one view left only one gets it.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+	WHERE id = $1 AND (expires IS NULL OR expires > NOW()) AND (max_views = 0 OR views < max_views)`
+
+	res, err := tx.Exec(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return nil, err
+	}
+
+	// No expiry check here: this may have been the last view
+	s, err := scanSnippet(tx.QueryRow(selectSnippets+` WHERE snippets.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, tx.Commit()
+}
+
+// Returns the snippet with the given id without counting a view.
+func (m *SnippetModel) Peek(id int) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.id = $1`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Returns the snippet with the given share link slug without counting a view.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.slug = $1`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Returns a page of the most recently created public snippets.
+func (m *SnippetModel) Latest(page models.Page) ([]*models.Snippet, error) {
+	return m.list("", nil, page)
+}
+
+// Returns a page of the most recently created public snippets tagged with tag.
+func (m *SnippetModel) ByTag(tag string, page models.Page) ([]*models.Snippet, error) {
+	where := ` AND snippets.id IN (SELECT snippet_tags.snippet_id FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id WHERE tags.name = $1)`
+
+	return m.list(where, []any{tag}, page)
+}
+
+// Returns a page of unexpired public snippets, newest first. where is appended
+// to the WHERE clause (and must start with AND) to narrow the list down
+// further, using the placeholders for args.
+func (m *SnippetModel) list(where string, args []any, page models.Page) ([]*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.visibility = 'public'` + where
+
+	// When paging forwards walk the index in ascending order so that LIMIT
+	// keeps the snippets closest to the cursor, then flip them round below.
+	switch {
+	case page.After > 0:
+		stmt += ` AND snippets.id > ` + param(&args, page.After) + ` ORDER BY snippets.id ASC`
+	case page.Before > 0:
+		stmt += ` AND snippets.id < ` + param(&args, page.Before) + ` ORDER BY snippets.id DESC`
+	default:
+		stmt += ` ORDER BY snippets.id DESC`
+	}
+	stmt += ` LIMIT ` + param(&args, page.Limit)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After > 0 {
+		slices.Reverse(snippets)
+	}
+
+	return snippets, nil
+}
+
+// Snippets which may turn up in search results, see the MySQL version
+const searchable = live + ` AND snippets.visibility = 'public' AND snippets.password_hash IS NULL AND snippets.max_views = 0`
+
+// The document searched for each snippet. Must match the expression of the
+// snippets_ft_title_content index for it to be used. The 'simple'
+// configuration doesn't stem words, which suits code better than a language.
+const document = `to_tsvector('simple', snippets.title || ' ' || snippets.content)`
+
+// Returns the public, unprotected snippets whose title or content contain
+// every word of query, best matches first. At most limit snippets are
+// returned, after skipping the first offset matches.
+func (m *SnippetModel) Search(query string, limit int, offset int) ([]*models.Snippet, error) {
+	stmt := selectSnippets + searchable + `
+	AND ` + document + ` @@ plainto_tsquery('simple', $1)
+	ORDER BY ts_rank(` + document + `, plainto_tsquery('simple', $1)) DESC, snippets.id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.Query(stmt, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
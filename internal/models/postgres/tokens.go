@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Wraps the connection pool
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Creates a new token for the user with the given id and returns its
+// plaintext (256 random bits in 43 URL safe characters).
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	plaintext, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, scope, token_hash, created) VALUES($1, $2, $3, $4, NOW())`
+
+	_, err = m.DB.Exec(stmt, userID, name, scope, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Returns the tokens of the user with the given id, newest first.
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens
+	WHERE user_id = $1 ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+	for rows.Next() {
+		t := &models.Token{}
+		var lastUsed sql.NullTime
+		err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revokes the token with the given id if it belongs to the user with userID.
+// Returns models.ErrNoRecord otherwise.
+func (m *TokenModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+
+	res, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Looks up the token with the given plaintext and records that it has been
+// used. Returns models.ErrInvalidCredentials if there is no such token.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens WHERE token_hash = $1`
+
+	t := &models.Token{}
+	var lastUsed sql.NullTime
+	err := m.DB.QueryRow(stmt, models.HashToken(plaintext)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidCredentials
+		} else {
+			return nil, err
+		}
+	}
+	t.LastUsed = lastUsed.Time
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = NOW() WHERE id = $1`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"snippetbox.victorsmith.dev/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Wraps the connection pool
+type UserModel struct {
+	DB *sql.DB
+}
+
+// Adds a user, returning models.ErrDuplicateEmail if the email is taken
+func (m *UserModel) Insert(name, email, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES($1, $2, $3, NOW())`
+
+	_, err = m.DB.Exec(stmt, name, email, string(hash))
+	if err != nil {
+		// 23505 is unique_violation, PostgreSQL names the constraint which
+		// was violated
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) {
+			if pgError.Code == "23505" && pgError.ConstraintName == "users_uc_email" {
+				return models.ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Returns the id of the user with the given email and password, or
+// models.ErrInvalidCredentials if there is no such user
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	var id int
+	var hashedPassword []byte
+	stmt := `SELECT id, hashed_password FROM users WHERE email = $1`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// Reports whether a user with the given id exists
+func (m *UserModel) Exists(id int) (bool, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM users WHERE id = $1)`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}
@@ -1,93 +1,154 @@
 package models
 
 import (
-	"database/sql"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Who gets to see a snippet
+const (
+	VisibilityPublic   = "public"   // listed on the home, tag and search pages
+	VisibilityUnlisted = "unlisted" // only reachable through its share link (/s/:slug)
+	VisibilityPrivate  = "private"  // only visible to its author
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	Title      string
+	Content    string
+	Language   string // chroma lexer name, "plaintext" for no highlighting
+	Markdown   bool   // render the content as Markdown instead of code
+	Created    time.Time
+	Expires    time.Time // zero if the snippet never expires
+	AuthorID   int
+	AuthorName string
+	Tags       []string // sorted by name
+	ParentID   int      // snippet this one was forked from, 0 if it wasn't
+	Forks      int      // number of snippets forked from this one
+	Visibility string   // one of the Visibility constants
+	Slug       string   // random, unguessable id used in share links
+	// bcrypt hash of the password needed to view the snippet, nil if there is none
+	PasswordHash []byte
+	Views        int // number of times the snippet has been read through Get
+	MaxViews     int // the snippet expires once it has been read this often, 0 for no limit
 }
 
-// Wraps the connection pool
-type SnippetModel struct {
-	DB *sql.DB
+// Reports whether the snippet has been read as often as it may be. Only
+// snippets returned by Get can be used up: every other lookup leaves them out.
+func (s *Snippet) UsedUp() bool {
+	return s.MaxViews > 0 && s.Views >= s.MaxViews
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-
-	stmt := `INSERT INTO snippets (title, content, created, expires) 
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-
-	res, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
+// Sets the password needed to view the snippet (hashed with bcrypt like user
+// passwords). An empty password removes the protection.
+func (s *Snippet) SetPassword(password string) error {
+	if password == "" {
+		s.PasswordHash = nil
+		return nil
 	}
 
-	// get id of newly inserted entry
-	id, err := res.LastInsertId()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return 0, err
+		return err
 	}
-
-	// convert id to int => return values
-	return int(id), nil
+	s.PasswordHash = hash
+	return nil
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
-
-	s := &Snippet{}
-	// returns a pointer to a sql.Row object which holds the result
-	row := m.DB.QueryRow(stmt, id)
-	// Provide address of desitnations in correct order
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-
+// Reports whether password unlocks the snippet
+func (s *Snippet) PasswordMatches(password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(s.PasswordHash, []byte(password))
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
 		} else {
-			return nil, err
+			return false, err
 		}
 	}
-	return s, nil
+	return true, nil
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-
-	rows, err := m.DB.Query(stmt)
+// Returns a new random slug for share links: 128 bits of randomness in 22
+// URL safe characters.
+func NewSlug() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	defer rows.Close()
-
-	snippets := []*Snippet{}
-
-	// use rows.Next to iterate through all results
-	for rows.Next() {
-		s := &Snippet{}
-
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-		if err != nil {
-			return nil, err
-		}
-		snippets = append(snippets, s)
-	}
+// Page selects a window of snippets ordered newest first. Rather than using
+// OFFSET (which gets slower the further back you go) the id of a neighbouring
+// snippet is used as the cursor, so every page is a cheap primary key range scan.
+type Page struct {
+	Before int // only snippets older than this id, 0 starts at the newest
+	After  int // only snippets newer than this id, takes precedence over Before
+	Limit  int
+}
 
-	// Get all errors encounterd in iteration
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+// An immutable copy of a snippet as it was after being created or edited.
+// Revisions are numbered from 1 (the snippet as first posted).
+type Revision struct {
+	SnippetID int
+	Number    int
+	Title     string
+	Content   string
+	Language  string
+	Markdown  bool
+	Created   time.Time
+}
 
-	return snippets, nil
+// Stores snippets along with their tags and revisions. Expired snippets,
+// whose time is up or which have been read MaxViews times, are never
+// returned, except by the Get which uses up their last view. Lookups of
+// missing snippets return ErrNoRecord.
+type SnippetStore interface {
+	// Adds a snippet with the title, content, language, format, visibility,
+	// password, view limit, tags, expiry and author (AuthorID) of s, gives
+	// it a new slug and records it as revision 1. Returns the new id.
+	Insert(s *Snippet) (int, error)
+	// Replaces the title, content, language, format, visibility, password,
+	// view limit, tags and expiry of the snippet with s.ID and records the
+	// result as its next revision. Views already counted are kept. Returns
+	// ErrNoRecord if the snippet doesn't exist (any more).
+	Update(s *Snippet) error
+	// Copies the snippet with the given id into a new snippet owned by
+	// userID with the original as its parent, a new slug and no views.
+	// Returns the id of the copy.
+	Fork(id int, userID int) (int, error)
+	// Sets a new expiry time, the zero time meaning never. Doesn't add a
+	// revision. Returns ErrNoRecord if the snippet doesn't exist (any more).
+	Renew(id int, expires time.Time) error
+	Delete(id int) error
+	// Removes up to limit expired snippets, oldest first, and returns how
+	// many were removed.
+	DeleteExpired(limit int) (int, error)
+
+	// Returns the snippet with the given id, counting it as a view. Of two
+	// concurrent reads of a snippet with one view left only one succeeds.
+	Get(id int) (*Snippet, error)
+	// Returns the snippet with the given id without counting a view.
+	Peek(id int) (*Snippet, error)
+	// Returns the snippet with the given share link slug, whatever its
+	// visibility, without counting a view.
+	GetBySlug(slug string) (*Snippet, error)
+	// Returns a page of the newest public snippets.
+	Latest(page Page) ([]*Snippet, error)
+	// Returns a page of the newest public snippets tagged with tag.
+	ByTag(tag string, page Page) ([]*Snippet, error)
+	// Returns the public snippets without a password or view limit whose
+	// title or content match query, best matches first. At most limit
+	// snippets are returned, after skipping the first offset matches.
+	Search(query string, limit int, offset int) ([]*Snippet, error)
+
+	// Returns every revision of a snippet, newest first.
+	Revisions(id int) ([]*Revision, error)
+	// Returns revision n of a snippet.
+	Revision(id int, n int) (*Revision, error)
 }
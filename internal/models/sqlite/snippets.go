@@ -0,0 +1,433 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+)
+
+// Wraps the connection pool
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+var (
+	_ models.SnippetStore = (*SnippetModel)(nil)
+	_ models.UserStore    = (*UserModel)(nil)
+	_ models.TokenStore   = (*TokenModel)(nil)
+)
+
+// Start of every query returning snippets, see the MySQL version for the
+// details. Must stay in sync with scanSnippet.
+const selectSnippets = `SELECT snippets.id, snippets.title, snippets.content, snippets.language, snippets.markdown, snippets.created, snippets.expires,
+	snippets.user_id, users.name,
+	(SELECT GROUP_CONCAT(tags.name, ',' ORDER BY tags.name) FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id
+		WHERE snippet_tags.snippet_id = snippets.id),
+	COALESCE(snippets.parent_id, 0),
+	(SELECT COUNT(*) FROM snippets AS forks WHERE forks.parent_id = snippets.id),
+	snippets.visibility, snippets.slug, snippets.password_hash, snippets.views, snippets.max_views
+	FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id`
+
+// Snippets which haven't expired, either because their time is up or because
+// they have been read as often as they may be. Takes the current time as its
+// only argument.
+const live = ` WHERE (snippets.expires IS NULL OR snippets.expires > ?)
+	AND (snippets.max_views = 0 OR snippets.views < snippets.max_views)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans a row selected with selectSnippets into a new Snippet
+func scanSnippet(row rowScanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var tags sql.NullString
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Language, &s.Markdown, &s.Created, &expires, &s.AuthorID, &s.AuthorName, &tags,
+		&s.ParentID, &s.Forks, &s.Visibility, &s.Slug, &s.PasswordHash, &s.Views, &s.MaxViews)
+	if err != nil {
+		return nil, err
+	}
+	// NULL (never expires) is left as the zero time
+	s.Expires = expires.Time
+	if tags.Valid {
+		s.Tags = strings.Split(tags.String, ",")
+	}
+	return s, nil
+}
+
+// Scans every row selected with selectSnippets
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Inserts a new snippet, see models.SnippetStore.
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, err := tx.Exec(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, slug, nullBytes(s.PasswordHash), s.MaxViews, now(), nullTime(s.Expires), s.AuthorID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, int(id))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// Replaces the editable fields of the snippet with s.ID, see models.SnippetStore.
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, markdown = ?, visibility = ?,
+	password_hash = ?, max_views = ?, expires = ? WHERE id = ?`
+
+	// Unlike MySQL, SQLite counts the rows matched, so a missing snippet is
+	// reported even if the edit changes nothing
+	res, err := tx.Exec(stmt, s.Title, s.Content, s.Language, s.Markdown, s.Visibility, nullBytes(s.PasswordHash), s.MaxViews, nullTime(s.Expires), s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = addRevision(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Copies the snippet with the given id into a new snippet owned by userID, see
+// models.SnippetStore.
+func (m *SnippetModel) Fork(id int, userID int) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (title, content, language, markdown, visibility, slug, password_hash, max_views, created, expires, user_id, parent_id)
+	SELECT title, content, language, markdown, visibility, ?, password_hash, max_views, ?, expires, ?, id
+	FROM snippets` + live + ` AND id = ?`
+
+	t := now()
+	res, err := tx.Exec(stmt, slug, t, userID, t, id)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nothing was copied if the original doesn't exist (or has expired)
+	err = checkAffected(res)
+	if err != nil {
+		return 0, err
+	}
+
+	forkID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT ?, tag_id FROM snippet_tags WHERE snippet_id = ?`
+
+	_, err = tx.Exec(stmt, forkID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = addRevision(tx, int(forkID))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(forkID), tx.Commit()
+}
+
+// Sets a new expiry time for the snippet with the given id, the zero time
+// meaning never.
+func (m *SnippetModel) Renew(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ?`
+
+	res, err := m.DB.Exec(stmt, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Replaces the tags attached to a snippet, creating any tags which don't exist yet.
+func setTags(tx *sql.Tx, id int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// The no-op update makes RETURNING give the existing tag's id when
+		// the name is already taken (DO NOTHING would return no row)
+		var tagID int
+		err := tx.QueryRow(`INSERT INTO tags (name) VALUES(?) ON CONFLICT (name) DO UPDATE SET name = excluded.name RETURNING id`, tag).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)`, id, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records the current state of a snippet as its next revision.
+func addRevision(tx *sql.Tx, id int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, language, markdown, created)
+	SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?),
+		title, content, language, markdown, ?
+	FROM snippets WHERE id = ?`
+
+	_, err := tx.Exec(stmt, id, now(), id)
+	return err
+}
+
+// Removes the snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	res, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// Removes up to limit expired snippets, oldest first. SQLite is usually built
+// without DELETE ... LIMIT, so the ids are picked by a subquery.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets
+		WHERE expires <= ? OR (max_views > 0 AND views >= max_views)
+		ORDER BY id LIMIT ?)`
+
+	res, err := m.DB.Exec(stmt, now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Reads the snippet with the given id, counting it as a view. As the write
+// lock is taken when the transaction begins, concurrent reads of a snippet
+// with one view left are serialized and only the first gets it.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+	WHERE id = ? AND (expires IS NULL OR expires > ?) AND (max_views = 0 OR views < max_views)`
+
+	res, err := tx.Exec(stmt, id, now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAffected(res)
+	if err != nil {
+		return nil, err
+	}
+
+	// No expiry check here: this may have been the last view
+	s, err := scanSnippet(tx.QueryRow(selectSnippets+` WHERE snippets.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, tx.Commit()
+}
+
+// Returns the snippet with the given id without counting a view.
+func (m *SnippetModel) Peek(id int) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.id = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, now(), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Returns the snippet with the given share link slug without counting a view.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, now(), slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Returns a page of the most recently created public snippets.
+func (m *SnippetModel) Latest(page models.Page) ([]*models.Snippet, error) {
+	return m.list("", nil, page)
+}
+
+// Returns a page of the most recently created public snippets tagged with tag.
+func (m *SnippetModel) ByTag(tag string, page models.Page) ([]*models.Snippet, error) {
+	where := ` AND snippets.id IN (SELECT snippet_tags.snippet_id FROM snippet_tags
+		INNER JOIN tags ON tags.id = snippet_tags.tag_id WHERE tags.name = ?)`
+
+	return m.list(where, []any{tag}, page)
+}
+
+// Returns a page of unexpired public snippets, newest first. where is appended
+// to the WHERE clause (and must start with AND) to narrow the list down further.
+func (m *SnippetModel) list(where string, args []any, page models.Page) ([]*models.Snippet, error) {
+	stmt := selectSnippets + live + ` AND snippets.visibility = 'public'` + where
+	args = append([]any{now()}, args...)
+
+	// When paging forwards walk the index in ascending order so that LIMIT
+	// keeps the snippets closest to the cursor, then flip them round below.
+	switch {
+	case page.After > 0:
+		stmt += ` AND snippets.id > ? ORDER BY snippets.id ASC LIMIT ?`
+		args = append(args, page.After, page.Limit)
+	case page.Before > 0:
+		stmt += ` AND snippets.id < ? ORDER BY snippets.id DESC LIMIT ?`
+		args = append(args, page.Before, page.Limit)
+	default:
+		stmt += ` ORDER BY snippets.id DESC LIMIT ?`
+		args = append(args, page.Limit)
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After > 0 {
+		slices.Reverse(snippets)
+	}
+
+	return snippets, nil
+}
+
+// Snippets which may turn up in search results, see the MySQL version
+const searchable = live + ` AND snippets.visibility = 'public' AND snippets.password_hash IS NULL AND snippets.max_views = 0`
+
+// Returns the public, unprotected snippets whose title or content contain
+// every word of query, newest first. There is no full text index, so this is
+// a (case insensitive for ASCII) substring match like MySQL's fallback.
+func (m *SnippetModel) Search(query string, limit int, offset int) ([]*models.Snippet, error) {
+	stmt := selectSnippets + searchable
+	args := []any{now()}
+
+	for _, word := range strings.Fields(query) {
+		stmt += ` AND (snippets.title LIKE ? ESCAPE '\' OR snippets.content LIKE ? ESCAPE '\')`
+		pattern := "%" + escapeLike(word) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	stmt += ` ORDER BY snippets.id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSnippets(rows)
+}
+
+// Escapes the LIKE wildcards in s so they are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,73 @@
+// Package sqlite implements the stores of the models package on SQLite, using
+// the pure Go driver modernc.org/sqlite so no C compiler is needed. Databases
+// have to be opened with Open.
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// Opens (creating it if needed) the database file at path. Every connection
+// enforces foreign keys (the schema relies on ON DELETE CASCADE), waits for
+// locks instead of failing straight away and takes the write lock when a
+// transaction begins, so two transactions never deadlock upgrading their
+// locks. Times are written in a format which sorts in time order.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Returns the current time as stored in the database. SQLite has no
+// UTC_TIMESTAMP(), so the time is passed in as a parameter instead.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// Converts an expiry time for storage: the zero time (never) becomes NULL.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// Converts a password hash for storage: nil (no password) becomes NULL rather
+// than an empty blob.
+func nullBytes(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// Returns models.ErrNoRecord if a statement didn't touch any rows
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
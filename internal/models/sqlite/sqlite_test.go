@@ -0,0 +1,204 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.victorsmith.dev/internal/assert"
+	"snippetbox.victorsmith.dev/internal/migrate"
+	"snippetbox.victorsmith.dev/internal/models"
+	"snippetbox.victorsmith.dev/migrations"
+)
+
+// Returns a new database in a temporary directory with every migration applied
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.Load(migrations.Files, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	countTable := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	_, err = (&migrate.Migrator{DB: db, Migrations: m, CountTable: countTable}).Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Adds a user and returns its id
+func newTestUser(t *testing.T, db *sql.DB, email string) int {
+	users := &UserModel{DB: db}
+	err := users.Insert("Alice", email, "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := users.Authenticate(email, "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestUserModel(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserModel{DB: db}
+	id := newTestUser(t, db, "alice@example.com")
+
+	err := users.Insert("Bob", "alice@example.com", "secret123")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	_, err = users.Authenticate("alice@example.com", "wrong")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	exists, err := users.Exists(id)
+	assert.Equal(t, exists, true)
+	assert.Equal(t, err, nil)
+
+	exists, err = users.Exists(id + 1)
+	assert.Equal(t, exists, false)
+	assert.Equal(t, err, nil)
+}
+
+func TestSnippetModel(t *testing.T) {
+	db := newTestDB(t)
+	snippets := &SnippetModel{DB: db}
+	author := newTestUser(t, db, "alice@example.com")
+
+	id, err := snippets.Insert(&models.Snippet{
+		Title:      "Deploy",
+		Content:    "kubectl apply -f 100%_done.yaml",
+		Language:   "bash",
+		Visibility: models.VisibilityPublic,
+		Tags:       []string{"k8s", "ops"},
+		Expires:    time.Now().Add(time.Hour),
+		AuthorID:   author,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Deploy")
+	assert.Equal(t, s.AuthorName, "Alice")
+	assert.Equal(t, s.Views, 1)
+	assert.Equal(t, len(s.Slug), 22)
+	assert.Equal(t, s.PasswordHash == nil, true)
+	assert.Equal(t, len(s.Tags), 2)
+
+	// Tags are shared, not duplicated
+	forkID, err := snippets.Fork(id, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTag, err := snippets.ByTag("k8s", models.Page{Limit: 10})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(byTag), 2)
+	assert.Equal(t, byTag[0].ID, forkID)
+	assert.Equal(t, byTag[1].Forks, 1)
+
+	// Wildcards are matched literally
+	found, err := snippets.Search("100%_", 10, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(found), 2)
+	found, err = snippets.Search("100_%", 10, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(found), 0)
+
+	s.Content = "kubectl delete -f done.yaml"
+	err = snippets.Update(s)
+	assert.Equal(t, err, nil)
+	revisions, err := snippets.Revisions(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Number, 2)
+
+	// Expired snippets are left out and purged
+	err = snippets.Renew(id, time.Now().Add(-time.Second))
+	assert.Equal(t, err, nil)
+	_, err = snippets.Peek(id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	n, err := snippets.DeleteExpired(10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 1)
+
+	fork, err := snippets.Peek(forkID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, fork.ParentID, 0)
+
+	// Changing a purged snippet is reported rather than quietly doing nothing
+	err = snippets.Update(s)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	err = snippets.Renew(id, time.Time{})
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSnippetModelMaxViews(t *testing.T) {
+	db := newTestDB(t)
+	snippets := &SnippetModel{DB: db}
+	author := newTestUser(t, db, "alice@example.com")
+
+	id, err := snippets.Insert(&models.Snippet{
+		Title:      "Secret",
+		Content:    "hunter2",
+		Language:   "plaintext",
+		Visibility: models.VisibilityUnlisted,
+		MaxViews:   1,
+		AuthorID:   author,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.UsedUp(), true)
+	assert.Equal(t, s.Expires.IsZero(), true)
+
+	_, err = snippets.Get(id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	_, err = snippets.GetBySlug(s.Slug)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestTokenModel(t *testing.T) {
+	db := newTestDB(t)
+	tokens := &TokenModel{DB: db}
+	user := newTestUser(t, db, "alice@example.com")
+
+	plaintext, err := tokens.Insert(user, "ci", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokens.Authenticate(plaintext)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, token.UserID, user)
+
+	list, err := tokens.ForUser(user)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(list), 1)
+	assert.Equal(t, list[0].LastUsed.IsZero(), false)
+
+	err = tokens.Delete(token.ID, user+1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+	err = tokens.Delete(token.ID, user)
+	assert.Equal(t, err, nil)
+
+	_, err = tokens.Authenticate(plaintext)
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+)
+
+// What an API token may be used for
+const (
+	ScopeRead  = "read"  // GET requests only
+	ScopeWrite = "write" // everything, including creating, changing and deleting snippets
+)
+
+// A personal API token. Only a SHA-256 hash of the token itself is stored, so
+// the plaintext is shown to its owner once, when it is created.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string // chosen by the owner to tell their tokens apart
+	Scope    string // one of the Scope constants
+	Created  time.Time
+	LastUsed time.Time // zero if the token has never been used
+}
+
+// Returns a new token (256 random bits in 43 URL safe characters) and the
+// hash to store for it.
+func NewToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	return plaintext, HashToken(plaintext), nil
+}
+
+// Hashes a plaintext token for storage and lookup. Tokens are random enough
+// that a fast unsalted hash is fine, unlike passwords.
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Stores API tokens
+type TokenStore interface {
+	// Creates a token for the user with the given id and returns its plaintext
+	Insert(userID int, name, scope string) (string, error)
+	// Returns the tokens of the user with the given id, newest first
+	ForUser(userID int) ([]*Token, error)
+	// Revokes the token with the given id if it belongs to the user with
+	// userID, returns ErrNoRecord otherwise
+	Delete(id, userID int) error
+	// Looks up the token with the given plaintext and records that it has
+	// been used. Returns ErrInvalidCredentials if there is no such token.
+	Authenticate(plaintext string) (*Token, error)
+}
@@ -1,6 +1,7 @@
 package validators
 
 import (
+	"cmp"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -9,6 +10,9 @@ import (
 // Returns a pointer to a compiled regexp.Regexp type
 var EmailRegexp = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// Tags are short lowercase words which may contain digits and inner dashes (e.g. "k8s", "on-call")
+var TagRegexp = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
 type Validator struct {
 	NonFieldErrors []string
 	FieldErrors    map[string]string
@@ -74,3 +78,23 @@ func MinChars(input string, n int) bool {
 func Matches(input string, rx *regexp.Regexp) bool {
 	return rx.MatchString(input)
 }
+
+// Returns true if every input matches the provided regexp (compiled)
+func AllMatch(inputs []string, rx *regexp.Regexp) bool {
+	for _, input := range inputs {
+		if !rx.MatchString(input) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the list holds no more than "max" items
+func MaxItems[T any](list []T, max int) bool {
+	return len(list) <= max
+}
+
+// Returns true if value lies between min and max (inclusive)
+func InRange[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
@@ -0,0 +1,12 @@
+package migrations
+
+import (
+	"embed"
+)
+
+// SQL migrations, one directory per database. Files are named
+// NNNN_description.up.sql and NNNN_description.down.sql and are applied in
+// order of their version number NNNN by internal/migrate.
+
+//go:embed "mysql" "postgres" "sqlite"
+var Files embed.FS
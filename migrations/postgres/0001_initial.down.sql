@@ -0,0 +1,7 @@
+DROP TABLE sessions;
+DROP TABLE api_tokens;
+DROP TABLE snippet_revisions;
+DROP TABLE snippet_tags;
+DROP TABLE tags;
+DROP TABLE snippets;
+DROP TABLE users;
@@ -0,0 +1,81 @@
+-- The same schema as mysql/0001_initial.up.sql.
+
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    markdown BOOLEAN NOT NULL DEFAULT FALSE,
+    created TIMESTAMPTZ NOT NULL,
+    expires TIMESTAMPTZ NULL,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug CHAR(22) NOT NULL,
+    password_hash BYTEA NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    max_views INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug),
+    CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT snippets_fk_parent_id FOREIGN KEY (parent_id) REFERENCES snippets(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+CREATE INDEX idx_snippets_parent_id ON snippets(parent_id);
+-- Must match the expression searched on in internal/models/postgres
+CREATE INDEX snippets_ft_title_content ON snippets USING GIN (to_tsvector('simple', title || ' ' || content));
+
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags(tag_id);
+
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL,
+    markdown BOOLEAN NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (snippet_id, revision)
+);
+
+CREATE TABLE api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    scope VARCHAR(5) NOT NULL,
+    token_hash BYTEA NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    last_used TIMESTAMPTZ NULL,
+    CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash)
+);
+
+-- Used by github.com/alexedwards/scs/postgresstore
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expiry TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);